@@ -1,7 +1,252 @@
+// Command cmd runs a CSV folder import from the command line, without the
+// desktop UI, so imports can be scheduled from cron, CI or an SSH session.
+//
+// Connection settings are layered: engine defaults, then the -config JSON
+// file, then CSV_IMPORT_* environment variables, then explicit flags.
 package main
 
-import "github.com/devakdogan/go_csv_adapter/internal/ui"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+	"github.com/devakdogan/go_csv_adapter/internal/importer"
+)
+
+const envPrefix = "CSV_IMPORT"
+
+var dbTypes = map[string]string{
+	"postgres":   db.PostgreSQLType,
+	"postgresql": db.PostgreSQLType,
+	"mysql":      db.MySQLType,
+	"sqlite":     db.SQLiteType,
+}
+
+// stringList is a repeatable flag; the first use replaces the defaults
+type stringList struct {
+	values []string
+	set    bool
+}
+
+func (l *stringList) String() string {
+	return strings.Join(l.values, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	if !l.set {
+		l.values, l.set = nil, true
+	}
+	l.values = append(l.values, v)
+	return nil
+}
+
+type cliFlags struct {
+	dbType     string
+	configPath string
+	connection db.DbConfig
+	folder     string
+	loadMethod string
+	ifExists   string
+	mergeKeys  string
+	shortRows  string
+	longRows   string
+	layouts    stringList
+	nulls      stringList
+	delimiter  string
+	quote      string
+	escape     string
+	comment    string
+	options    importer.Options
+	set        map[string]bool
+}
 
 func main() {
-	ui.StartApp()
+	f := parseFlags()
+	if err := run(f); err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *cliFlags {
+	f := &cliFlags{options: importer.DefaultOptions(), set: make(map[string]bool)}
+
+	flag.StringVar(&f.dbType, "db", "postgres", "database type: postgres, mysql or sqlite")
+	flag.StringVar(&f.configPath, "config", "", "JSON file with host, port, user, password and database")
+	flag.StringVar(&f.connection.Host, "host", "", "database host")
+	flag.IntVar(&f.connection.Port, "port", 0, "database port")
+	flag.StringVar(&f.connection.User, "user", "", "database user")
+	flag.StringVar(&f.connection.Password, "password", "", "database password (prefer $"+envPrefix+"_PASSWORD)")
+	flag.StringVar(&f.connection.Database, "database", "", "database name, or file path for sqlite")
+	flag.StringVar(&f.folder, "folder", "", "folder containing the CSV files to import")
+	flag.IntVar(&f.options.BatchSize, "batch-size", f.options.BatchSize, "rows per INSERT statement or native bulk load; INSERTs are lowered to fit the engine's parameter limit (0 = largest that fits)")
+	flag.IntVar(&f.options.Workers, "workers", f.options.Workers, "number of concurrent insert workers")
+	flag.BoolVar(&f.options.Transactional, "transactional", false, "load each file in one transaction so a failed file leaves the database untouched")
+	flag.IntVar(&f.options.SampleSize, "sample-size", f.options.SampleSize, "rows examined to infer column types (0 = whole file)")
+	flag.StringVar(&f.loadMethod, "load-method", string(importer.LoadAuto), "auto, insert or native (COPY on PostgreSQL, LOAD DATA LOCAL INFILE on MySQL, where a batch with any warning is sent again as INSERTs)")
+	flag.Var(&f.nulls, "null", `cell value loaded as NULL; repeat for several, -null "" for empty cells only (default: empty, NULL and \N)`)
+	flag.Var(&f.layouts, "date-layout", "Go time layout tried for date/time columns, or unix / unixms; repeat to give several in order of preference (default: a built-in ISO, RFC3339, DD/MM and MM/DD list)")
+	flag.StringVar(&f.delimiter, "delimiter", "auto", "field delimiter, e.g. , ; | or tab (auto = detect per file)")
+	flag.StringVar(&f.quote, "quote", `"`, "quote character")
+	flag.StringVar(&f.escape, "escape", "", "character escaping quotes inside quoted fields, e.g. \\ (default: doubled quotes)")
+	flag.StringVar(&f.comment, "comment", "", "lines starting with this character are skipped")
+	flag.BoolVar(&f.options.CSV.LazyQuotes, "lazy-quotes", false, "accept quotes in unquoted fields and unescaped quotes in quoted fields")
+	flag.BoolVar(&f.options.CSV.TrimLeadingSpace, "trim-leading-space", false, "ignore spaces at the start of fields")
+	flag.IntVar(&f.options.CSV.SkipLines, "skip-lines", 0, "lines to skip before the header, e.g. report titles")
+	flag.BoolVar(&f.options.CSV.NoHeader, "no-header", false, "the first row is data; columns are named col_1..col_n unless a schema file lists headers")
+	flag.StringVar(&f.ifExists, "if-exists", string(importer.ConflictAppend), "when a table exists: append, truncate, replace, fail or create-new (a schema file's if_exists wins)")
+	flag.BoolVar(&f.options.EvolveSchema, "evolve-schema", false, "add new CSV columns to existing tables and widen column types where no value is lost")
+	flag.StringVar(&f.mergeKeys, "merge-keys", "", "comma-separated columns identifying a row; rows already in the table are updated instead of added (a schema file's merge_keys wins)")
+	flag.StringVar(&f.shortRows, "short-rows", string(importer.RaggedAbort), "rows with fewer fields than the header: pad, reject or abort")
+	flag.StringVar(&f.longRows, "long-rows", string(importer.RaggedAbort), "rows with more fields than the header: truncate, reject or abort")
+	flag.IntVar(&f.options.MaxErrors, "max-errors", f.options.MaxErrors, "rejected rows allowed per file before it is stopped (-1 = no limit); rejected rows go to <file>.csv.rejected.csv")
+	flag.BoolVar(&f.options.Headers.SnakeCase, "snake-case", false, "turn headers into snake_case column names")
+	flag.BoolVar(&f.options.Headers.Transliterate, "transliterate", false, "replace Turkish letters in headers with ASCII ones")
+	flag.BoolVar(&f.options.Headers.AvoidReserved, "avoid-reserved", false, "append _ to headers that are reserved words")
+	flag.StringVar(&f.options.CSV.Encoding, "encoding", importer.EncodingAuto, "file encoding, e.g. utf-8, windows-1254, iso-8859-9 (auto = detect per file)")
+	flag.BoolVar(&f.options.DryRun, "dry-run", false, "validate every row and print the DDL, row counts and failing rows without touching the database")
+	flag.BoolVar(&f.options.MonthFirst, "month-first", false, "read dates that fit both orders, such as 03/04/2024, as month-first")
+	flag.Parse()
+
+	flag.Visit(func(fl *flag.Flag) {
+		f.set[fl.Name] = true
+	})
+	return f
+}
+
+func run(f *cliFlags) error {
+	dbType, ok := dbTypes[strings.ToLower(f.dbType)]
+	if !ok {
+		return fmt.Errorf("unsupported database type: %s", f.dbType)
+	}
+	if f.folder == "" {
+		return fmt.Errorf("-folder is required")
+	}
+	if f.options.BatchSize < 0 {
+		return fmt.Errorf("-batch-size must not be negative")
+	}
+	if f.options.SampleSize < 0 {
+		return fmt.Errorf("-sample-size must not be negative")
+	}
+	if f.options.Workers <= 0 {
+		return fmt.Errorf("-workers must be positive")
+	}
+	method, err := importer.ParseLoadMethod(f.loadMethod)
+	if err != nil {
+		return err
+	}
+	f.options.LoadMethod = method
+	if f.options.IfExists, err = importer.ParseConflictStrategy(f.ifExists); err != nil {
+		return err
+	}
+	f.options.MergeKeys = importer.ParseMergeKeys(f.mergeKeys)
+	if f.options.ShortRows, err = importer.ParseRaggedPolicy(f.shortRows, false); err != nil {
+		return err
+	}
+	if f.options.LongRows, err = importer.ParseRaggedPolicy(f.longRows, true); err != nil {
+		return err
+	}
+	if f.layouts.set {
+		f.options.TemporalLayouts = f.layouts.values
+	}
+	if f.nulls.set {
+		f.options.NullTokens = f.nulls.values
+	}
+	for _, c := range []struct {
+		name  string
+		value string
+		dst   *rune
+	}{
+		{"delimiter", f.delimiter, &f.options.CSV.Delimiter},
+		{"quote", f.quote, &f.options.CSV.Quote},
+		{"escape", f.escape, &f.options.CSV.Escape},
+		{"comment", f.comment, &f.options.CSV.Comment},
+	} {
+		if *c.dst, err = parseChar(c.value); err != nil {
+			return fmt.Errorf("-%s: %w", c.name, err)
+		}
+	}
+	if err := f.options.CSV.Validate(); err != nil {
+		return err
+	}
+
+	config, err := connectionConfig(dbType, f)
+	if err != nil {
+		return err
+	}
+	// A dry run never connects, so it needs no connection settings
+	if !f.options.DryRun {
+		if err := config.Validate(dbType); err != nil {
+			return err
+		}
+	}
+
+	return importer.ImportCSVFiles(f.folder, dbType, config, f.options, importer.NewTextReporter(os.Stdout))
+}
+
+func connectionConfig(dbType string, f *cliFlags) (db.DbConfig, error) {
+	config := db.DefaultDbConfig(dbType)
+	if f.configPath != "" {
+		fromFile, err := db.LoadDbConfig(f.configPath)
+		if err != nil {
+			return config, err
+		}
+		mergeConfig(&config, fromFile)
+	}
+	if err := config.ApplyEnv(envPrefix); err != nil {
+		return config, err
+	}
+
+	if f.set["host"] {
+		config.Host = f.connection.Host
+	}
+	if f.set["port"] {
+		config.Port = f.connection.Port
+	}
+	if f.set["user"] {
+		config.User = f.connection.User
+	}
+	if f.set["password"] {
+		config.Password = f.connection.Password
+	}
+	if f.set["database"] {
+		config.Database = f.connection.Database
+	}
+	return config, nil
+}
+
+// parseChar reads a single-character flag; "" and auto leave it unset
+func parseChar(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 {
+		return 0, fmt.Errorf("%q is not a single character", s)
+	}
+	return r[0], nil
+}
+
+// mergeConfig copies the non-empty fields of src into dst
+func mergeConfig(dst *db.DbConfig, src db.DbConfig) {
+	if src.Host != "" {
+		dst.Host = src.Host
+	}
+	if src.Port != 0 {
+		dst.Port = src.Port
+	}
+	if src.User != "" {
+		dst.User = src.User
+	}
+	if src.Password != "" {
+		dst.Password = src.Password
+	}
+	if src.Database != "" {
+		dst.Database = src.Database
+	}
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+	"github.com/devakdogan/go_csv_adapter/internal/importer"
+)
+
+// testFlags are the flag defaults, as parseFlags leaves them when no flag
+// is given
+func testFlags(folder string) *cliFlags {
+	return &cliFlags{
+		dbType:     "mysql",
+		folder:     folder,
+		loadMethod: string(importer.LoadAuto),
+		ifExists:   string(importer.ConflictAppend),
+		shortRows:  string(importer.RaggedAbort),
+		longRows:   string(importer.RaggedAbort),
+		delimiter:  "auto",
+		quote:      `"`,
+		options:    importer.DefaultOptions(),
+		set:        make(map[string]bool),
+	}
+}
+
+func TestRunDryRunNeedsNoConnection(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "t.csv"), []byte("id\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// -port 0 fails validation, which only a real import needs
+	f := testFlags(dir)
+	f.set["port"] = true
+	f.options.DryRun = true
+	if err := run(f); err != nil {
+		t.Errorf("dry run: %v", err)
+	}
+
+	f = testFlags(dir)
+	f.set["port"] = true
+	if err := run(f); err == nil || !strings.Contains(err.Error(), "port must be between 1 and 65535") {
+		t.Errorf("import: got %v, want the port refused", err)
+	}
+}
+
+func TestConnectionConfigLayering(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "db.json")
+	if err := os.WriteFile(configPath, []byte(`{"host": "file-host", "port": 3307, "user": "file-user", "database": "shop"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// defaults, then the file, then the environment, then flags
+	t.Setenv("CSV_IMPORT_USER", "env-user")
+	t.Setenv("CSV_IMPORT_PASSWORD", "env-secret")
+	t.Setenv("CSV_IMPORT_DATABASE", "env-db")
+	f := testFlags(dir)
+	f.configPath = configPath
+	f.connection = db.DbConfig{Host: "ignored", Port: 1, Database: "flag-db"}
+	f.set["database"] = true
+
+	config, err := connectionConfig(db.MySQLType, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := db.DbConfig{Host: "file-host", Port: 3307, User: "env-user", Password: "env-secret", Database: "flag-db"}
+	if config != want {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+
+	// a flag set to its zero value still wins over the layers below it
+	f.set["port"] = true
+	f.connection.Port = 0
+	if config, err := connectionConfig(db.MySQLType, f); err != nil || config.Port != 0 {
+		t.Errorf("-port 0: got port %d, %v", config.Port, err)
+	}
+}
+
+func TestConnectionConfigDefaults(t *testing.T) {
+	config, err := connectionConfig(db.PostgreSQLType, testFlags(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := db.DefaultDbConfig(db.PostgreSQLType); config != want {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+}
+
+func TestConnectionConfigErrors(t *testing.T) {
+	f := testFlags(t.TempDir())
+	f.configPath = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := connectionConfig(db.MySQLType, f); err == nil {
+		t.Error("missing config file: no error")
+	}
+
+	t.Setenv("CSV_IMPORT_PORT", "5432x")
+	if _, err := connectionConfig(db.MySQLType, testFlags(t.TempDir())); err == nil || !strings.Contains(err.Error(), "CSV_IMPORT_PORT") {
+		t.Errorf("bad port: got %v, want an error naming CSV_IMPORT_PORT", err)
+	}
+}
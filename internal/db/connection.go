@@ -1,7 +1,28 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+)
 
 type DBProvider interface {
 	Connect() (*sql.DB, error)
+	Dialect() Dialect
+}
+
+// NewProvider validates config and returns the provider for dbType
+func NewProvider(dbType string, config DbConfig) (DBProvider, error) {
+	if err := config.Validate(dbType); err != nil {
+		return nil, err
+	}
+	switch dbType {
+	case PostgreSQLType:
+		return &Postgres{Config: config.ToPostgresConfig()}, nil
+	case MySQLType:
+		return &MySQL{Config: config.ToMySQLConfig()}, nil
+	case SQLiteType:
+		return &SQLite{Config: config.ToSQLiteConfig()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
 }
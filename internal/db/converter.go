@@ -1,45 +1,132 @@
 package db
 
 import (
-	"fyne.io/fyne/v2/widget"
+	"encoding/json"
+	"fmt"
+	"os"
 	"strconv"
 )
 
+// Supported database types, as shown in the UI and accepted by NewProvider
+const (
+	PostgreSQLType = "PostgreSQL"
+	MySQLType      = "MySQL"
+	SQLiteType     = "SQLite"
+)
+
+// DbConfig holds connection settings as plain values, so they can come from
+// the UI form, a JSON file, environment variables or command-line flags.
+// For SQLite only Database is used, as the path of the database file.
 type DbConfig struct {
-	Host       *widget.Entry
-	Port       *widget.Entry
-	User       *widget.Entry
-	Password   *widget.Entry
-	Database   *widget.Entry
-	Configured bool
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// DefaultDbConfig returns the usual local settings for dbType
+func DefaultDbConfig(dbType string) DbConfig {
+	switch dbType {
+	case PostgreSQLType:
+		return DbConfig{Host: "localhost", Port: 5432, User: "postgres", Database: "postgres"}
+	case MySQLType:
+		return DbConfig{Host: "localhost", Port: 3306, User: "root", Database: "mysql"}
+	case SQLiteType:
+		return DbConfig{Database: "local.db"}
+	default:
+		return DbConfig{}
+	}
+}
+
+// LoadDbConfig reads a DbConfig from a JSON file
+func LoadDbConfig(path string) (DbConfig, error) {
+	var c DbConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides fields from <prefix>_HOST, <prefix>_PORT, <prefix>_USER,
+// <prefix>_PASSWORD and <prefix>_DATABASE when they are set.
+func (c *DbConfig) ApplyEnv(prefix string) error {
+	if v, ok := os.LookupEnv(prefix + "_HOST"); ok {
+		c.Host = v
+	}
+	if v, ok := os.LookupEnv(prefix + "_PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s_PORT: %w", prefix, err)
+		}
+		c.Port = port
+	}
+	if v, ok := os.LookupEnv(prefix + "_USER"); ok {
+		c.User = v
+	}
+	if v, ok := os.LookupEnv(prefix + "_PASSWORD"); ok {
+		c.Password = v
+	}
+	if v, ok := os.LookupEnv(prefix + "_DATABASE"); ok {
+		c.Database = v
+	}
+	return nil
+}
+
+// Validate checks that the fields dbType needs are present and in range
+func (c *DbConfig) Validate(dbType string) error {
+	switch dbType {
+	case PostgreSQLType, MySQLType:
+		if c.Host == "" {
+			return fmt.Errorf("host is required")
+		}
+		if c.Port < 1 || c.Port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535, got %d", c.Port)
+		}
+		if c.User == "" {
+			return fmt.Errorf("user is required")
+		}
+		if c.Database == "" {
+			return fmt.Errorf("database is required")
+		}
+	case SQLiteType:
+		if c.Database == "" {
+			return fmt.Errorf("database file path is required")
+		}
+	default:
+		return fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	return nil
 }
 
 // Dönüştürücüler
 func (c *DbConfig) ToPostgresConfig() PostgresConfig {
-	port, _ := strconv.Atoi(c.Port.Text)
 	return PostgresConfig{
-		Host:     c.Host.Text,
-		Port:     port,
-		User:     c.User.Text,
-		Password: c.Password.Text,
-		DBName:   c.Database.Text,
+		Host:     c.Host,
+		Port:     c.Port,
+		User:     c.User,
+		Password: c.Password,
+		DBName:   c.Database,
 		SSLMode:  "disable",
 	}
 }
 
 func (c *DbConfig) ToMySQLConfig() MySQLConfig {
-	port, _ := strconv.Atoi(c.Port.Text)
 	return MySQLConfig{
-		Host:     c.Host.Text,
-		Port:     port,
-		User:     c.User.Text,
-		Password: c.Password.Text,
-		DBName:   c.Database.Text,
+		Host:     c.Host,
+		Port:     c.Port,
+		User:     c.User,
+		Password: c.Password,
+		DBName:   c.Database,
 	}
 }
 
 func (c *DbConfig) ToSQLiteConfig() SQLiteConfig {
 	return SQLiteConfig{
-		FilePath: c.Database.Text,
+		FilePath: c.Database,
 	}
 }
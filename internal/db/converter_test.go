@@ -0,0 +1,86 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("TEST_DB_HOST", "db.internal")
+	t.Setenv("TEST_DB_PORT", "6432")
+	t.Setenv("TEST_DB_PASSWORD", "")
+	c := DbConfig{Host: "localhost", Port: 5432, User: "postgres", Password: "secret", Database: "postgres"}
+	if err := c.ApplyEnv("TEST_DB"); err != nil {
+		t.Fatal(err)
+	}
+	// a variable set to "" still overrides; unset ones leave the field alone
+	want := DbConfig{Host: "db.internal", Port: 6432, User: "postgres", Database: "postgres"}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	t.Setenv("TEST_DB_PORT", "five")
+	if err := c.ApplyEnv("TEST_DB"); err == nil || !strings.Contains(err.Error(), "TEST_DB_PORT") {
+		t.Errorf("bad port: got %v, want an error naming the variable", err)
+	}
+}
+
+func TestLoadDbConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	if err := os.WriteFile(path, []byte(`{"host": "h", "port": 3307, "database": "shop"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadDbConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (DbConfig{Host: "h", Port: 3307, Database: "shop"}); c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"port": "3307"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDbConfig(bad); err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("bad file: got %v, want an error naming it", err)
+	}
+	if _, err := LoadDbConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	server := DefaultDbConfig(PostgreSQLType)
+	cases := []struct {
+		name    string
+		dbType  string
+		edit    func(c *DbConfig)
+		wantErr string
+	}{
+		{name: "defaults", dbType: PostgreSQLType},
+		{name: "no host", dbType: MySQLType, edit: func(c *DbConfig) { c.Host = "" }, wantErr: "host is required"},
+		{name: "port 0", dbType: PostgreSQLType, edit: func(c *DbConfig) { c.Port = 0 }, wantErr: "port must be between 1 and 65535, got 0"},
+		{name: "port too high", dbType: PostgreSQLType, edit: func(c *DbConfig) { c.Port = 65536 }, wantErr: "got 65536"},
+		{name: "no user", dbType: PostgreSQLType, edit: func(c *DbConfig) { c.User = "" }, wantErr: "user is required"},
+		{name: "no database", dbType: MySQLType, edit: func(c *DbConfig) { c.Database = "" }, wantErr: "database is required"},
+		{name: "SQLite needs only a file", dbType: SQLiteType, edit: func(c *DbConfig) { *c = DbConfig{Database: "a.db"} }},
+		{name: "SQLite without a file", dbType: SQLiteType, edit: func(c *DbConfig) { c.Database = "" }, wantErr: "database file path is required"},
+		{name: "unknown type", dbType: "Oracle", wantErr: "unsupported database type: Oracle"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := server
+			if c.edit != nil {
+				c.edit(&config)
+			}
+			err := config.Validate(c.dbType)
+			if c.wantErr == "" && err != nil || c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Errorf("Validate = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
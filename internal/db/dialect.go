@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ColumnType is the engine-neutral type inferred for a CSV column. Each
+// Dialect maps it to a concrete SQL type.
+type ColumnType string
+
+const (
+	TypeBool        ColumnType = "bool"
+	TypeInt         ColumnType = "int"    // fits in 32 bits
+	TypeBigInt      ColumnType = "bigint" // fits in 64 bits
+	TypeDecimal     ColumnType = "decimal"
+	TypeFloat       ColumnType = "float"
+	TypeDate        ColumnType = "date"
+	TypeTime        ColumnType = "time"
+	TypeTimestamp   ColumnType = "timestamp"   // without time zone
+	TypeTimestampTZ ColumnType = "timestamptz" // an absolute instant
+	TypeUUID        ColumnType = "uuid"
+	TypeJSON        ColumnType = "json"
+	TypeText        ColumnType = "string"
+)
+
+// Dialect covers the SQL differences between the supported engines
+type Dialect interface {
+	// Name is the database type, e.g. PostgreSQLType.
+	Name() string
+	// QuoteIdent quotes a table or column name, escaping embedded quotes.
+	QuoteIdent(name string) string
+	// Placeholder returns the bind parameter for the n-th argument, from 1.
+	Placeholder(n int) string
+	// MapType returns the column type used in CREATE TABLE.
+	MapType(t ColumnType) string
+	// MapDecimal returns the exact numeric type for precision digits, scale
+	// of them after the point, or a lossless fallback when the engine's
+	// decimal type cannot hold them.
+	MapDecimal(precision, scale int) string
+	// MaxParams is the most bind parameters a single statement may use.
+	MaxParams() int
+	// AcceptsTime reports whether the driver takes time.Time for date and
+	// timestamp columns; otherwise they are bound as ISO text.
+	AcceptsTime() bool
+	// MaxIdentifierLength is the longest table or column name in bytes,
+	// or 0 when the engine has no practical limit.
+	MaxIdentifierLength() int
+	// IsReserved reports whether name is a reserved word, which only works
+	// as a column name when quoted.
+	IsReserved(name string) bool
+	// TruncateTable returns the fastest statement that empties table. It
+	// may commit implicitly, so transactions use DELETE instead.
+	TruncateTable(table string) string
+	// AlterColumnType returns the statement that changes col of table to
+	// sqlType, or "" when the engine cannot do so without losing something.
+	AlterColumnType(table string, col Column, sqlType string) string
+	// UpsertClause is appended to an INSERT so that rows whose keys already
+	// exist update the remaining columns instead of failing.
+	UpsertClause(keys []string, columns []string) string
+}
+
+// BulkLoader is implemented by dialects that have a native bulk-load path
+// which is faster than multi-row INSERT statements.
+type BulkLoader interface {
+	// BulkLoad sends rows to table through the native loader inside tx.
+	// Values are nil for NULL, bool, int64, float64, time.Time or string.
+	BulkLoad(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error
+	// BulkLoadByDefault reports whether the loader needs no server-side
+	// setup, so the importer may pick it without being asked.
+	BulkLoadByDefault() bool
+}
+
+// DialectFor returns the dialect of dbType without connecting to anything
+func DialectFor(dbType string) (Dialect, error) {
+	switch dbType {
+	case PostgreSQLType:
+		return PostgresDialect{}, nil
+	case MySQLType:
+		return MySQLDialect{}, nil
+	case SQLiteType:
+		return SQLiteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+// quoteWith wraps name in quote, doubling any quote characters inside it
+func quoteWith(name string, quote string) string {
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+// QuoteLiteral writes s as a SQL string literal. MySQL also treats
+// backslashes as escapes, so they are doubled there.
+func QuoteLiteral(d Dialect, s string) string {
+	if d.Name() == MySQLType {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return quoteWith(s, "'")
+}
+
+// nonKeyColumns returns columns that are not in keys
+func nonKeyColumns(keys []string, columns []string) []string {
+	isKey := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		isKey[k] = true
+	}
+	var rest []string
+	for _, c := range columns {
+		if !isKey[c] {
+			rest = append(rest, c)
+		}
+	}
+	return rest
+}
+
+// onConflictClause is the ON CONFLICT form shared by PostgreSQL and SQLite
+func onConflictClause(d Dialect, keys []string, columns []string) string {
+	quotedKeys := make([]string, len(keys))
+	for i, k := range keys {
+		quotedKeys[i] = d.QuoteIdent(k)
+	}
+	rest := nonKeyColumns(keys, columns)
+	if len(rest) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(quotedKeys, ", "))
+	}
+	sets := make([]string, len(rest))
+	for i, c := range rest {
+		q := d.QuoteIdent(c)
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", q, q)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(quotedKeys, ", "), strings.Join(sets, ", "))
+}
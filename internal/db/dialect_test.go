@@ -0,0 +1,57 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestQuoteIdent(t *testing.T) {
+	cases := []struct {
+		dialect Dialect
+		name    string
+		want    string
+	}{
+		{PostgresDialect{}, "orders", `"orders"`},
+		{PostgresDialect{}, `say "hi"`, `"say ""hi"""`},
+		{PostgresDialect{}, `"`, `""""`},
+		{PostgresDialect{}, "it's `x`", "\"it's `x`\""},
+		{SQLiteDialect{}, `a"b`, `"a""b"`},
+		{SQLiteDialect{}, "a; DROP TABLE t", `"a; DROP TABLE t"`},
+		{MySQLDialect{}, "orders", "`orders`"},
+		{MySQLDialect{}, "a`b", "`a``b`"},
+		{MySQLDialect{}, "`", "````"},
+		{MySQLDialect{}, `say "hi"`, "`say \"hi\"`"},
+	}
+	for _, c := range cases {
+		if got := c.dialect.QuoteIdent(c.name); got != c.want {
+			t.Errorf("%s: QuoteIdent(%q) = %s, want %s", c.dialect.Name(), c.name, got, c.want)
+		}
+	}
+}
+
+// TestQuoteIdentRoundTrip checks that SQLite reads a quoted name back as
+// the name itself
+func TestQuoteIdentRoundTrip(t *testing.T) {
+	provider, err := NewProvider(SQLiteType, DbConfig{Database: filepath.Join(t.TempDir(), "t.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := provider.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d := SQLiteDialect{}
+	table, column := `odd "table"`, `a""b "c`
+	if _, err := conn.Exec("CREATE TABLE " + d.QuoteIdent(table) + " (" + d.QuoteIdent(column) + " TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	columns, err := TableColumns(conn, d, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 1 || columns[0].Name != column {
+		t.Errorf("columns = %+v, want one named %q", columns, column)
+	}
+}
@@ -3,6 +3,12 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type MySQLConfig struct {
@@ -25,3 +31,232 @@ func (m *MySQL) Connect() (*sql.DB, error) {
 	)
 	return sql.Open("mysql", dsn)
 }
+
+func (m *MySQL) Dialect() Dialect {
+	return MySQLDialect{}
+}
+
+type MySQLDialect struct{}
+
+func (MySQLDialect) Name() string {
+	return MySQLType
+}
+
+// QuoteIdent uses backticks, which work whether or not ANSI_QUOTES is set
+func (MySQLDialect) QuoteIdent(name string) string {
+	return quoteWith(name, "`")
+}
+
+func (MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (MySQLDialect) MapType(t ColumnType) string {
+	switch t {
+	case TypeBool:
+		return "BOOLEAN"
+	case TypeInt:
+		return "INT"
+	case TypeBigInt:
+		return "BIGINT"
+	case TypeDecimal:
+		// Plain DECIMAL means DECIMAL(10,0) in MySQL and drops the fraction
+		return "DECIMAL(65,30)"
+	case TypeFloat:
+		return "DOUBLE"
+	case TypeDate:
+		return "DATE"
+	case TypeTime:
+		return "TIME(6)"
+	case TypeTimestamp:
+		return "DATETIME(6)"
+	case TypeTimestampTZ:
+		// TIMESTAMP stops at 2038, so instants are kept as UTC DATETIMEs
+		return "DATETIME(6)"
+	case TypeUUID:
+		return "CHAR(36)"
+	case TypeJSON:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+func (MySQLDialect) MapDecimal(precision, scale int) string {
+	if precision < 1 || precision > 65 || scale > 30 || scale > precision {
+		// Wider than DECIMAL allows; TEXT keeps every digit
+		return "TEXT"
+	}
+	return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale)
+}
+
+func (MySQLDialect) MaxParams() int {
+	return 65535
+}
+
+func (MySQLDialect) AcceptsTime() bool {
+	return true
+}
+
+// MaxIdentifierLength is 64. MySQL counts characters, so capping bytes is
+// stricter than needed
+func (MySQLDialect) MaxIdentifierLength() int {
+	return 64
+}
+
+func (MySQLDialect) IsReserved(name string) bool {
+	return isReserved(name, mysqlReserved)
+}
+
+// TruncateTable commits implicitly, like any DDL in MySQL
+func (d MySQLDialect) TruncateTable(table string) string {
+	return "TRUNCATE TABLE " + d.QuoteIdent(table)
+}
+
+// AlterColumnType restates NOT NULL, which MODIFY COLUMN would drop. It
+// would drop the default too, so columns with one are left alone.
+func (d MySQLDialect) AlterColumnType(table string, col Column, sqlType string) string {
+	if col.HasDefault {
+		return ""
+	}
+	stmt := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", d.QuoteIdent(table), d.QuoteIdent(col.Name), sqlType)
+	if !col.Nullable {
+		stmt += " NOT NULL"
+	}
+	return stmt
+}
+
+func (d MySQLDialect) UpsertClause(keys []string, columns []string) string {
+	rest := nonKeyColumns(keys, columns)
+	if len(rest) == 0 {
+		// Nothing to update, but the clause still turns the duplicate into a no-op
+		q := d.QuoteIdent(keys[0])
+		return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", q, q)
+	}
+	sets := make([]string, len(rest))
+	for i, c := range rest {
+		q := d.QuoteIdent(c)
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", q, q)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// MySQLKeyLength is the VARCHAR length of text merge key columns. MySQL
+// cannot put a whole TEXT column in a unique index.
+const MySQLKeyLength = 255
+
+// loadDataSeq keeps reader handler names unique across concurrent loads
+var loadDataSeq atomic.Uint64
+
+// BulkLoad streams rows through LOAD DATA LOCAL INFILE using a registered
+// reader handler, so nothing is written to disk. The server must allow it
+// with local_infile=ON, which is why it is only used when asked for.
+//
+// LOCAL implies IGNORE: values that don't fit are coerced and duplicate
+// keys skipped, with only a warning. Any warning or missing row therefore
+// fails the batch, so the caller can roll it back and find the bad rows.
+func (d MySQLDialect) BulkLoad(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	name := fmt.Sprintf("csv_import_%d", loadDataSeq.Add(1))
+	pr, pw := io.Pipe()
+	mysql.RegisterReaderHandler(name, func() io.Reader { return pr })
+	defer mysql.DeregisterReaderHandler(name)
+
+	go func() {
+		pw.CloseWithError(writeLoadData(pw, rows, len(columns)))
+	}()
+
+	quotedCols := make([]string, len(columns))
+	for i, c := range columns {
+		quotedCols[i] = d.QuoteIdent(c)
+	}
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s "+
+		"CHARACTER SET utf8mb4 "+
+		"FIELDS TERMINATED BY ',' ENCLOSED BY '\"' ESCAPED BY '\\\\' "+
+		"LINES TERMINATED BY '\\n' (%s)",
+		name, d.QuoteIdent(table), strings.Join(quotedCols, ", "))
+
+	res, err := tx.Exec(query)
+	// Unblock the writer if the server stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return err
+	}
+
+	var warnings int
+	if err := tx.QueryRow("SELECT @@warning_count").Scan(&warnings); err != nil {
+		return err
+	}
+	if warnings > 0 {
+		var level, message string
+		var code int
+		if err := tx.QueryRow("SHOW WARNINGS LIMIT 1").Scan(&level, &code, &message); err != nil {
+			return fmt.Errorf("LOAD DATA gave %d warnings", warnings)
+		}
+		return fmt.Errorf("LOAD DATA gave %d warnings, the first: %s (%d)", warnings, message, code)
+	}
+	if n, err := res.RowsAffected(); err == nil && n != int64(len(rows)) {
+		return fmt.Errorf("LOAD DATA loaded %d of %d rows", n, len(rows))
+	}
+	return nil
+}
+
+func (MySQLDialect) BulkLoadByDefault() bool {
+	return false
+}
+
+// loadDataEscaper escapes field contents for ESCAPED BY '\\'
+var loadDataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x00", `\0`,
+	"\x1a", `\Z`,
+)
+
+// writeLoadData writes rows in the format the LOAD DATA statement above
+// expects: every field enclosed in double quotes and backslash-escaped, and
+// NULL as an unenclosed \N.
+func writeLoadData(w io.Writer, rows [][]interface{}, columns int) error {
+	var line strings.Builder
+	for _, row := range rows {
+		if len(row) != columns {
+			return fmt.Errorf("row has %d fields, expected %d", len(row), columns)
+		}
+		line.Reset()
+		for i, v := range row {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			if v == nil {
+				line.WriteString(`\N`)
+				continue
+			}
+			line.WriteByte('"')
+			line.WriteString(loadDataEscaper.Replace(loadDataText(v)))
+			line.WriteByte('"')
+		}
+		line.WriteByte('\n')
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDataText formats a bound value the way MySQL parses it from text
+func loadDataText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999")
+	default:
+		return fmt.Sprint(v)
+	}
+}
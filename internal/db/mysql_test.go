@@ -0,0 +1,55 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLoadData(t *testing.T) {
+	cases := []struct {
+		name string
+		row  []interface{}
+		want string
+	}{
+		{"plain text", []interface{}{"abc", "şehir"}, `"abc","şehir"` + "\n"},
+		{"backslash and quotes", []interface{}{`C:\dir`, `say "hi"`, "it's"}, `"C:\\dir","say \"hi\"","it's"` + "\n"},
+		{"line breaks", []interface{}{"a\nb", "c\r\nd"}, `"a\nb","c\r\nd"` + "\n"},
+		{"NUL and Ctrl-Z", []interface{}{"a\x00b", "\x1a"}, `"a\0b","\Z"` + "\n"},
+		{"NULL is an unenclosed marker", []interface{}{nil, "", `\N`}, `\N,"","\\N"` + "\n"},
+		{"commas stay inside the quotes", []interface{}{"a,b"}, `"a,b"` + "\n"},
+		{"numbers and booleans", []interface{}{int64(-7), 1.5, true, false}, `"-7","1.5","1","0"` + "\n"},
+		{
+			"times",
+			[]interface{}{
+				time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 4, 10, 5, 6, 120000000, time.UTC),
+				time.Date(2024, 3, 4, 10, 5, 6, 123456789, time.UTC),
+			},
+			`"2024-03-04 00:00:00","2024-03-04 10:05:06.12","2024-03-04 10:05:06.123456"` + "\n",
+		},
+	}
+	for _, c := range cases {
+		var out strings.Builder
+		if err := writeLoadData(&out, [][]interface{}{c.row}, len(c.row)); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if out.String() != c.want {
+			t.Errorf("%s: wrote %q, want %q", c.name, out.String(), c.want)
+		}
+	}
+}
+
+func TestWriteLoadDataRows(t *testing.T) {
+	var out strings.Builder
+	rows := [][]interface{}{{"a", int64(1)}, {nil, int64(2)}}
+	if err := writeLoadData(&out, rows, 2); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"a\",\"1\"\n\\N,\"2\"\n"; out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+	if err := writeLoadData(&out, [][]interface{}{{"a"}}, 2); err == nil {
+		t.Error("a short row was written")
+	}
+}
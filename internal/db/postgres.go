@@ -3,7 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type PostgresConfig struct {
@@ -24,3 +24,116 @@ func (p *Postgres) Connect() (*sql.DB, error) {
 		p.Config.User, p.Config.Password, p.Config.DBName, p.Config.Host, p.Config.Port, p.Config.SSLMode)
 	return sql.Open("postgres", connStr)
 }
+
+func (p *Postgres) Dialect() Dialect {
+	return PostgresDialect{}
+}
+
+type PostgresDialect struct{}
+
+func (PostgresDialect) Name() string {
+	return PostgreSQLType
+}
+
+func (PostgresDialect) QuoteIdent(name string) string {
+	return quoteWith(name, `"`)
+}
+
+func (PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (PostgresDialect) MapType(t ColumnType) string {
+	switch t {
+	case TypeBool:
+		return "BOOLEAN"
+	case TypeInt:
+		return "INTEGER"
+	case TypeBigInt:
+		return "BIGINT"
+	case TypeDecimal:
+		return "NUMERIC"
+	case TypeFloat:
+		return "DOUBLE PRECISION"
+	case TypeDate:
+		return "DATE"
+	case TypeTime:
+		return "TIME"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeTimestampTZ:
+		return "TIMESTAMPTZ"
+	case TypeUUID:
+		return "UUID"
+	case TypeJSON:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+func (PostgresDialect) MapDecimal(precision, scale int) string {
+	if precision < 1 || precision > 1000 || scale > precision {
+		return "NUMERIC"
+	}
+	return fmt.Sprintf("NUMERIC(%d,%d)", precision, scale)
+}
+
+func (PostgresDialect) MaxParams() int {
+	return 65535
+}
+
+func (PostgresDialect) AcceptsTime() bool {
+	return true
+}
+
+// MaxIdentifierLength is NAMEDATALEN-1; longer names are cut silently
+func (PostgresDialect) MaxIdentifierLength() int {
+	return 63
+}
+
+func (PostgresDialect) IsReserved(name string) bool {
+	return isReserved(name, postgresReserved)
+}
+
+func (d PostgresDialect) TruncateTable(table string) string {
+	return "TRUNCATE TABLE " + d.QuoteIdent(table)
+}
+
+func (d PostgresDialect) AlterColumnType(table string, col Column, sqlType string) string {
+	c := d.QuoteIdent(col.Name)
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", d.QuoteIdent(table), c, sqlType, c, sqlType)
+}
+
+func (d PostgresDialect) UpsertClause(keys []string, columns []string) string {
+	return onConflictClause(d, keys, columns)
+}
+
+// BulkLoad streams rows with COPY FROM STDIN, which has no bind parameter
+// limit and avoids building huge INSERT statements.
+func (PostgresDialect) BulkLoad(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			stmt.Close()
+			return fmt.Errorf("row has %d fields, expected %d", len(row), len(columns))
+		}
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	// An Exec without arguments flushes the buffered rows to the server
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+func (PostgresDialect) BulkLoadByDefault() bool {
+	return true
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TableExists reports whether table exists, from sqlite_master or
+// information_schema. An error means it could not be told, not that the
+// table is missing.
+func TableExists(conn Queryer, d Dialect, table string) (bool, error) {
+	var query string
+	switch d.Name() {
+	case SQLiteType:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	case MySQLType:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	}
+	var n int
+	if err := conn.QueryRow(query, table).Scan(&n); err != nil {
+		return false, fmt.Errorf("checking whether table %s exists: %w", table, err)
+	}
+	return n > 0, nil
+}
+
+// Queryer is the part of *sql.DB and *sql.Tx that schema changes need
+type Queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Column is a column of an existing table
+type Column struct {
+	Name string
+	// SQLType is the type as the engine reports it, and Type the closest
+	// ColumnType, or "" when there is none.
+	SQLType    string
+	Type       ColumnType
+	Nullable   bool
+	HasDefault bool
+	// Precision and Scale size a decimal column; a Precision of 0 means
+	// the column takes any number.
+	Precision int
+	Scale     int
+}
+
+// TableColumns lists the columns of table in order, from
+// information_schema, or PRAGMA table_info on SQLite
+func TableColumns(conn Queryer, d Dialect, table string) ([]Column, error) {
+	var rows *sql.Rows
+	var err error
+	switch d.Name() {
+	case SQLiteType:
+		rows, err = conn.Query(fmt.Sprintf("SELECT name, type, \"notnull\" = 0, dflt_value IS NOT NULL FROM pragma_table_info(%s)", QuoteLiteral(d, table)))
+	case MySQLType:
+		rows, err = conn.Query(`SELECT column_name, column_type, is_nullable = 'YES', column_default IS NOT NULL
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position`, table)
+	default:
+		// data_type drops the precision that NUMERIC(p,s) was declared with
+		rows, err = conn.Query(`SELECT column_name,
+				CASE WHEN data_type = 'numeric' AND numeric_precision IS NOT NULL
+					THEN 'numeric(' || numeric_precision || ',' || numeric_scale || ')'
+					ELSE data_type END,
+				is_nullable = 'YES', column_default IS NOT NULL
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position`, table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var c Column
+		if err := rows.Scan(&c.Name, &c.SQLType, &c.Nullable, &c.HasDefault); err != nil {
+			return nil, err
+		}
+		c.Type = columnTypeOf(c.SQLType)
+		if c.Type == TypeDecimal {
+			c.Precision, c.Scale = decimalSize(c.SQLType)
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// columnTypeOf reads the type names the three engines report, such as
+// "integer" or "int(11)", "timestamp without time zone" or "datetime(6)"
+func columnTypeOf(sqlType string) ColumnType {
+	name := strings.ToLower(strings.TrimSpace(sqlType))
+	if strings.HasPrefix(name, "tinyint(1)") {
+		// MySQL's BOOLEAN
+		return TypeBool
+	}
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+	name = strings.TrimSuffix(name, " unsigned")
+	switch name {
+	case "bool", "boolean":
+		return TypeBool
+	case "tinyint", "smallint", "mediumint", "int", "integer", "int2", "int4":
+		return TypeInt
+	case "bigint", "int8":
+		return TypeBigInt
+	case "numeric", "decimal":
+		return TypeDecimal
+	case "real", "float", "float4", "float8", "double", "double precision":
+		return TypeFloat
+	case "date":
+		return TypeDate
+	case "time", "time without time zone":
+		return TypeTime
+	case "timestamp", "datetime", "timestamp without time zone":
+		return TypeTimestamp
+	case "timestamptz", "timestamp with time zone":
+		return TypeTimestampTZ
+	case "uuid":
+		return TypeUUID
+	case "json", "jsonb":
+		return TypeJSON
+	case "text", "tinytext", "mediumtext", "longtext", "varchar", "character varying", "char", "character", "bpchar":
+		return TypeText
+	}
+	return ""
+}
+
+// decimalSize reads the precision and scale of a type such as
+// "decimal(12,2)" or "NUMERIC(5)", or 0, 0 when it has none
+func decimalSize(sqlType string) (precision int, scale int) {
+	open, end := strings.IndexByte(sqlType, '('), strings.IndexByte(sqlType, ')')
+	if open < 0 || end < open {
+		return 0, 0
+	}
+	p, s, _ := strings.Cut(sqlType[open+1:end], ",")
+	precision, err := strconv.Atoi(strings.TrimSpace(p))
+	if err != nil {
+		return 0, 0
+	}
+	if s != "" {
+		if scale, err = strconv.Atoi(strings.TrimSpace(s)); err != nil {
+			return 0, 0
+		}
+	}
+	return precision, scale
+}
+
+// EnsureUniqueIndex creates a unique index called name on columns of table
+// unless an index of that name is already there, and reports whether it
+// did. MySQL can only index the start of a TEXT column, which would let
+// keys that share it overwrite each other, so such columns are refused.
+func EnsureUniqueIndex(conn Queryer, d Dialect, table string, name string, columns []string) (bool, error) {
+	var query string
+	switch d.Name() {
+	case SQLiteType:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?"
+	case MySQLType:
+		query = "SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname = $2"
+	}
+	var n int
+	if err := conn.QueryRow(query, table, name).Scan(&n); err != nil || n > 0 {
+		return false, err
+	}
+
+	parts := make([]string, len(columns))
+	for i, c := range columns {
+		parts[i] = d.QuoteIdent(c)
+		if d.Name() != MySQLType {
+			continue
+		}
+		var dataType string
+		err := conn.QueryRow(`SELECT data_type FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`, table, c).Scan(&dataType)
+		if err != nil {
+			return false, fmt.Errorf("looking up column %s: %w", c, err)
+		}
+		if strings.HasSuffix(strings.ToLower(dataType), "text") || strings.HasSuffix(strings.ToLower(dataType), "blob") {
+			return false, fmt.Errorf("column %s is %s, which MySQL cannot index whole; make it VARCHAR(%d)", c, strings.ToUpper(dataType), MySQLKeyLength)
+		}
+	}
+	_, err := conn.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)",
+		d.QuoteIdent(name), d.QuoteIdent(table), strings.Join(parts, ", ")))
+	return err == nil, err
+}
@@ -1,6 +1,12 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
 
 type SQLiteConfig struct {
 	FilePath string
@@ -11,5 +17,106 @@ type SQLite struct {
 }
 
 func (s *SQLite) Connect() (*sql.DB, error) {
-	return sql.Open("sqlite", s.Config.FilePath)
+	dsn := s.Config.FilePath
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	// Wait for locks instead of failing immediately with SQLITE_BUSY
+	dsn += "_pragma=busy_timeout(5000)"
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, so let the insert workers queue on one
+	// connection instead of failing with "database is locked".
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+func (s *SQLite) Dialect() Dialect {
+	return SQLiteDialect{}
+}
+
+type SQLiteDialect struct{}
+
+func (SQLiteDialect) Name() string {
+	return SQLiteType
+}
+
+func (SQLiteDialect) QuoteIdent(name string) string {
+	return quoteWith(name, `"`)
+}
+
+func (SQLiteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (SQLiteDialect) MapType(t ColumnType) string {
+	switch t {
+	case TypeBool:
+		return "BOOLEAN"
+	case TypeInt, TypeBigInt:
+		return "INTEGER"
+	case TypeDecimal:
+		return "NUMERIC"
+	case TypeFloat:
+		return "REAL"
+	case TypeDate:
+		return "DATE"
+	case TypeTime:
+		return "TIME"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeTimestampTZ:
+		return "TIMESTAMPTZ"
+	default:
+		// UUID and JSON are stored as text
+		return "TEXT"
+	}
+}
+
+// MapDecimal keeps NUMERIC affinity only while a REAL round-trips every
+// digit; longer values are stored as TEXT so none are lost
+func (SQLiteDialect) MapDecimal(precision, scale int) string {
+	if precision < 1 || precision > 15 || scale > precision {
+		return "TEXT"
+	}
+	return fmt.Sprintf("NUMERIC(%d,%d)", precision, scale)
+}
+
+// MaxParams is SQLITE_MAX_VARIABLE_NUMBER for SQLite 3.32 and later
+func (SQLiteDialect) MaxParams() int {
+	return 32766
+}
+
+// AcceptsTime is false: the driver would write dates as full timestamps,
+// and SQLite compares them as text
+func (SQLiteDialect) AcceptsTime() bool {
+	return false
+}
+
+func (SQLiteDialect) MaxIdentifierLength() int {
+	return 0
+}
+
+func (SQLiteDialect) IsReserved(name string) bool {
+	return isReserved(name, sqliteReserved)
+}
+
+// TruncateTable uses DELETE, SQLite has no TRUNCATE
+func (d SQLiteDialect) TruncateTable(table string) string {
+	return "DELETE FROM " + d.QuoteIdent(table)
+}
+
+// AlterColumnType is never possible, SQLite cannot change a column's type.
+// Its columns take values of any type anyway.
+func (SQLiteDialect) AlterColumnType(table string, col Column, sqlType string) string {
+	return ""
+}
+
+func (d SQLiteDialect) UpsertClause(keys []string, columns []string) string {
+	return onConflictClause(d, keys, columns)
 }
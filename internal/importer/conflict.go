@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// ConflictStrategy says what happens when a file's table already exists
+type ConflictStrategy string
+
+const (
+	// ConflictAppend loads the rows into the existing table.
+	ConflictAppend ConflictStrategy = "append"
+	// ConflictTruncate empties the table first.
+	ConflictTruncate ConflictStrategy = "truncate"
+	// ConflictReplace drops the table and creates it again from the plan.
+	ConflictReplace ConflictStrategy = "replace"
+	// ConflictFail leaves the table alone and fails the file.
+	ConflictFail ConflictStrategy = "fail"
+	// ConflictCreateNew imports into a new table named after the old one
+	// with a timestamp, e.g. orders_20240131_154500.
+	ConflictCreateNew ConflictStrategy = "create-new"
+)
+
+// ConflictStrategies lists every strategy, for flags and settings
+var ConflictStrategies = []ConflictStrategy{
+	ConflictAppend, ConflictTruncate, ConflictReplace, ConflictFail, ConflictCreateNew,
+}
+
+// now stamps the tables that create-new makes; tests fix it
+var now = time.Now
+
+// ParseConflictStrategy validates a strategy name from flags, settings or a
+// schema file
+func ParseConflictStrategy(s string) (ConflictStrategy, error) {
+	if s == "" {
+		return ConflictAppend, nil
+	}
+	for _, c := range ConflictStrategies {
+		if string(c) == strings.ToLower(s) {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown table strategy %q (want append, truncate, replace, fail or create-new)", s)
+}
+
+// resolveTable applies the parts of the plan's strategy that decide which
+// table is loaded: fail stops here, and create-new returns a copy of the
+// plan aimed at a fresh table. existed is whether the returned plan's table
+// is already there.
+func resolveTable(conn *sql.DB, dialect db.Dialect, plan *FilePlan, reporter Reporter) (*FilePlan, bool, error) {
+	table := plan.Schema.Table
+	exists, err := db.TableExists(conn, dialect, table)
+	if err != nil || !exists {
+		return plan, false, err
+	}
+	switch plan.IfExists {
+	case ConflictFail:
+		return nil, true, fmt.Errorf("table %s already exists", table)
+	case ConflictCreateNew:
+		suffix := "_" + now().Format("20060102_150405")
+		name := truncateIdentifier(table, dialect.MaxIdentifierLength()-len(suffix)) + suffix
+		for n := 2; ; n++ {
+			taken, err := db.TableExists(conn, dialect, name)
+			if err != nil {
+				return nil, true, err
+			}
+			if !taken {
+				break
+			}
+			more := fmt.Sprintf("%s_%d", suffix, n)
+			name = truncateIdentifier(table, dialect.MaxIdentifierLength()-len(more)) + more
+		}
+		reporter.Log(fmt.Sprintf("Table %s exists, importing into %s instead", table, name))
+		renamed := *plan
+		renamed.Schema.Table = name
+		return &renamed, false, nil
+	}
+	return plan, true, nil
+}
+
+// emptyTable empties an existing table when the plan's strategy is
+// truncate. Inside a transaction it uses DELETE, since TRUNCATE may commit.
+func emptyTable(conn execer, dialect db.Dialect, plan *FilePlan, existed bool, inTx bool, reporter Reporter) error {
+	if !existed || plan.IfExists != ConflictTruncate {
+		return nil
+	}
+	table := plan.Schema.Table
+	reporter.Log(fmt.Sprintf("Table %s exists, emptying it", table))
+	stmt := dialect.TruncateTable(table)
+	if inTx {
+		stmt = "DELETE FROM " + dialect.QuoteIdent(table)
+	}
+	if _, err := conn.Exec(stmt); err != nil {
+		return fmt.Errorf("emptying table %s: %w", table, err)
+	}
+	return nil
+}
+
+// defineTable runs the DDL a plan needs: it drops the table when the
+// strategy is replace, creates it when it isn't there, or else fits it to
+// the file, and adds the merge keys' unique index. altered is whether a
+// table that existed before was changed.
+func defineTable(conn execer, dialect db.Dialect, plan *FilePlan, existed bool, opts Options, reporter Reporter) (defined *FilePlan, altered bool, err error) {
+	table := plan.Schema.Table
+	if existed && plan.IfExists == ConflictReplace {
+		reporter.Log(fmt.Sprintf("Table %s exists, dropping and recreating it", table))
+		if _, err := conn.Exec("DROP TABLE " + dialect.QuoteIdent(table)); err != nil {
+			return plan, false, fmt.Errorf("dropping table %s: %w", table, err)
+		}
+		existed = false
+	}
+	if existed {
+		plan, altered, err = evolveTable(conn, dialect, plan, opts.EvolveSchema, reporter)
+	} else if _, err = conn.Exec(GenerateCreateTableSQL(dialect, plan.Schema)); err != nil {
+		err = fmt.Errorf("creating table %s: %w", table, err)
+	}
+	if err != nil || len(plan.Schema.MergeKeys) == 0 {
+		return plan, altered, err
+	}
+	created, err := ensureMergeIndex(conn, dialect, plan.Schema)
+	return plan, altered || (existed && created), err
+}
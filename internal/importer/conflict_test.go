@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+func TestParseConflictStrategy(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    ConflictStrategy
+		wantErr bool
+	}{
+		{"", ConflictAppend, false},
+		{"append", ConflictAppend, false},
+		{"TRUNCATE", ConflictTruncate, false},
+		{"Replace", ConflictReplace, false},
+		{"fail", ConflictFail, false},
+		{"create-new", ConflictCreateNew, false},
+		{"create_new", "", true},
+		{"overwrite", "", true},
+	}
+	for _, c := range cases {
+		got, err := ParseConflictStrategy(c.in)
+		if got != c.want || (err != nil) != c.wantErr {
+			t.Errorf("ParseConflictStrategy(%q) = %q, %v; want %q, error %v", c.in, got, err, c.want, c.wantErr)
+		}
+	}
+}
+
+func TestResolveTable(t *testing.T) {
+	stamp := time.Date(2024, 1, 31, 15, 45, 0, 0, time.Local)
+	now = func() time.Time { return stamp }
+	t.Cleanup(func() { now = time.Now })
+
+	cases := []struct {
+		name     string
+		tables   []string
+		ifExists ConflictStrategy
+		// wantTable is the table loaded, or "" when the file fails
+		wantTable   string
+		wantExisted bool
+	}{
+		{name: "missing table", ifExists: ConflictFail, wantTable: "t"},
+		{name: "append", tables: []string{"t"}, ifExists: ConflictAppend, wantTable: "t", wantExisted: true},
+		{name: "truncate", tables: []string{"t"}, ifExists: ConflictTruncate, wantTable: "t", wantExisted: true},
+		{name: "replace", tables: []string{"t"}, ifExists: ConflictReplace, wantTable: "t", wantExisted: true},
+		{name: "fail", tables: []string{"t"}, ifExists: ConflictFail},
+		{name: "create-new", tables: []string{"t"}, ifExists: ConflictCreateNew, wantTable: "t_20240131_154500"},
+		{
+			name:      "create-new in the same second",
+			tables:    []string{"t", "t_20240131_154500", "t_20240131_154500_2"},
+			ifExists:  ConflictCreateNew,
+			wantTable: "t_20240131_154500_3",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			provider, err := db.NewProvider(db.SQLiteType, db.DbConfig{Database: filepath.Join(t.TempDir(), "t.db")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := provider.Connect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			for _, table := range c.tables {
+				if _, err := conn.Exec(`CREATE TABLE "` + table + `" (id INTEGER)`); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plan := &FilePlan{Schema: TableSchema{Table: "t"}, IfExists: c.ifExists}
+			resolved, existed, err := resolveTable(conn, db.SQLiteDialect{}, plan, NopReporter{})
+			if c.wantTable == "" {
+				if err == nil {
+					t.Fatalf("resolveTable loads %s, want an error", resolved.Schema.Table)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved.Schema.Table != c.wantTable || existed != c.wantExisted {
+				t.Errorf("resolveTable = %s, existed %v; want %s, %v", resolved.Schema.Table, existed, c.wantTable, c.wantExisted)
+			}
+			if plan.Schema.Table != "t" {
+				t.Errorf("the original plan was renamed to %s", plan.Schema.Table)
+			}
+		})
+	}
+}
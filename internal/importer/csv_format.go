@@ -0,0 +1,529 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// How much of a file is read to detect its encoding and delimiter
+const sniffSize = 16 * 1024
+
+const (
+	EncodingAuto = "auto"
+	EncodingUTF8 = "utf-8"
+)
+
+var (
+	utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+	// Tried in this order; a tie goes to the earlier one
+	delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+	// ğ Ğ ş Ş ı İ in Windows-1254. In Windows-1252 these are ð Ð þ Þ ý Ý,
+	// which are rare enough to tell the two apart.
+	turkishBytes = map[byte]bool{0xF0: true, 0xD0: true, 0xFE: true, 0xDE: true, 0xFD: true, 0xDD: true}
+)
+
+// CSVFormat describes how the CSV files of an import are written. Zero
+// values are detected per file (delimiter, encoding) or take the RFC 4180
+// default (quote).
+type CSVFormat struct {
+	Delimiter rune
+	Quote     rune
+	// Escape is the character that escapes a quote inside a quoted field,
+	// such as a backslash. 0 means quotes are escaped by doubling them.
+	Escape rune
+	// Comment starts a line that is skipped; 0 means no comments.
+	Comment          rune
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	// Encoding is a WHATWG label such as utf-8, windows-1254 or
+	// iso-8859-9, or auto.
+	Encoding string
+	// SkipLines is the number of lines before the header, such as report
+	// titles.
+	SkipLines int
+	// NoHeader means the first record is already data. Columns are named
+	// col_1 to col_n unless a schema file names them.
+	NoHeader bool
+}
+
+func (f CSVFormat) String() string {
+	s := fmt.Sprintf("delimiter %q, encoding %s", string(f.Delimiter), f.Encoding)
+	if f.Quote != 0 && f.Quote != '"' {
+		s += fmt.Sprintf(", quote %q", string(f.Quote))
+	}
+	if f.Escape != 0 {
+		s += fmt.Sprintf(", escape %q", string(f.Escape))
+	}
+	if f.SkipLines > 0 {
+		s += fmt.Sprintf(", skipping %d lines", f.SkipLines)
+	}
+	if f.NoHeader {
+		s += ", no header"
+	}
+	return s
+}
+
+// Validate checks the settings before any file is opened
+func (f CSVFormat) Validate() error {
+	if f.SkipLines < 0 {
+		return fmt.Errorf("lines to skip must not be negative")
+	}
+	if !isAutoEncoding(f.Encoding) {
+		if _, err := htmlindex.Get(f.Encoding); err != nil {
+			return fmt.Errorf("unknown encoding %q", f.Encoding)
+		}
+	}
+	used := make(map[rune]string)
+	for _, c := range []struct {
+		name string
+		r    rune
+	}{{"delimiter", f.Delimiter}, {"quote", f.Quote}, {"escape", f.Escape}, {"comment", f.Comment}} {
+		if c.r == 0 {
+			continue
+		}
+		if c.r == '\r' || c.r == '\n' || c.r == utf8.RuneError {
+			return fmt.Errorf("invalid %s character %q", c.name, string(c.r))
+		}
+		if other, ok := used[c.r]; ok && !(c.name == "escape" && other == "quote") {
+			return fmt.Errorf("the %s and %s characters must differ", other, c.name)
+		}
+		used[c.r] = c.name
+	}
+	return nil
+}
+
+func isAutoEncoding(name string) bool {
+	return name == "" || strings.EqualFold(name, EncodingAuto)
+}
+
+func isUTF8Encoding(name string) bool {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8", "unicode-1-1-utf-8":
+		return true
+	}
+	return false
+}
+
+// sniffFormat fills in the encoding and delimiter of format from the start
+// of the file when they are left to detection
+func sniffFormat(filePath string, format CSVFormat) (CSVFormat, error) {
+	if format.Quote == 0 {
+		format.Quote = '"'
+	}
+	if !isAutoEncoding(format.Encoding) && format.Delimiter != 0 {
+		return format, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return format, err
+	}
+	defer f.Close()
+	sample := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return format, err
+	}
+	truncated := n == sniffSize
+	sample = sample[:n]
+
+	if isAutoEncoding(format.Encoding) {
+		format.Encoding = detectEncoding(sample, truncated)
+	}
+	if format.Delimiter == 0 {
+		text := string(sample)
+		if !isUTF8Encoding(format.Encoding) {
+			enc, err := htmlindex.Get(format.Encoding)
+			if err != nil {
+				return format, err
+			}
+			decoded, _, _ := transform.Bytes(enc.NewDecoder(), sample)
+			text = string(decoded)
+		}
+		for i := 0; i < format.SkipLines && text != ""; i++ {
+			_, text, _ = strings.Cut(text, "\n")
+		}
+		format.Delimiter = detectDelimiter(strings.TrimPrefix(text, "\ufeff"), format.Quote, truncated)
+	}
+	return format, nil
+}
+
+// detectEncoding recognises BOMs and UTF-8, and otherwise picks between the
+// Turkish and Western Windows code pages
+func detectEncoding(sample []byte, truncated bool) string {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+	if isUTF8(sample, truncated) {
+		return EncodingUTF8
+	}
+	for _, b := range sample {
+		if turkishBytes[b] {
+			return "windows-1254"
+		}
+	}
+	return "windows-1252"
+}
+
+// isUTF8 allows a truncated sample to end part way through a character
+func isUTF8(sample []byte, truncated bool) bool {
+	if utf8.Valid(sample) {
+		return true
+	}
+	if !truncated {
+		return false
+	}
+	for i := 1; i < utf8.UTFMax && i < len(sample); i++ {
+		if utf8.Valid(sample[:len(sample)-i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectDelimiter picks the candidate that appears the same number of times
+// on every sampled line, preferring the one the header uses most. Quoted
+// text is skipped. A truncated sample's last line is ignored.
+func detectDelimiter(sample string, quote rune, truncated bool) rune {
+	counts := make(map[rune][]int)
+	line := make(map[rune]int)
+	inQuotes, blank := false, true
+	endLine := func() {
+		// Blank lines are skipped by the CSV reader too
+		if blank {
+			return
+		}
+		for _, d := range delimiterCandidates {
+			counts[d] = append(counts[d], line[d])
+			line[d] = 0
+		}
+		blank = true
+	}
+	for _, r := range sample {
+		switch {
+		case r == quote:
+			inQuotes, blank = !inQuotes, false
+		case inQuotes:
+		case r == '\n':
+			endLine()
+		case r == '\r':
+		default:
+			line[r]++
+			blank = false
+		}
+	}
+	if !truncated {
+		endLine()
+	}
+
+	best, bestConsistent, bestCount := ',', false, 0
+	for _, d := range delimiterCandidates {
+		lines := counts[d]
+		if len(lines) == 0 || lines[0] == 0 {
+			continue
+		}
+		consistent := true
+		for _, n := range lines[1:] {
+			if n != lines[0] && n != 0 {
+				consistent = false
+			}
+		}
+		if (consistent && !bestConsistent) || (consistent == bestConsistent && lines[0] > bestCount) {
+			best, bestConsistent, bestCount = d, consistent, lines[0]
+		}
+	}
+	return best
+}
+
+// csvSource is an open CSV file decoded to UTF-8. Input counts the raw
+// bytes read, so progress can be measured against Size.
+type csvSource struct {
+	reader *csv.Reader
+	file   *os.File
+	input  *countingReader
+	Size   int64
+
+	noHeader bool
+	// skipped is the number of lines dropped before the header
+	skipped int
+	// pending is the first record of a headerless file, read to count its
+	// columns and handed out again by the next Read
+	pending     []string
+	pendingLine int
+	line        int
+}
+
+// openCSV opens filePath for reading with a format resolved by sniffFormat
+func openCSV(filePath string, format CSVFormat) (*csvSource, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	input := &countingReader{r: f}
+	var rd io.Reader = input
+	if !isUTF8Encoding(format.Encoding) {
+		enc, err := htmlindex.Get(format.Encoding)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unknown encoding %q", format.Encoding)
+		}
+		rd = transform.NewReader(rd, enc.NewDecoder())
+	}
+	buffered := bufio.NewReader(rd)
+	if bom, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+		_, _ = buffered.Discard(len(utf8BOM))
+	}
+	skipped := 0
+	for ; skipped < format.SkipLines; skipped++ {
+		if _, err := buffered.ReadString('\n'); err != nil {
+			break
+		}
+	}
+	rd = buffered
+	if (format.Quote != 0 && format.Quote != '"') || format.Escape != 0 {
+		rd = newQuoteRewriter(buffered, format)
+	}
+
+	r := csv.NewReader(rd)
+	r.FieldsPerRecord = -1
+	if format.Delimiter != 0 {
+		r.Comma = format.Delimiter
+	}
+	r.Comment = format.Comment
+	r.LazyQuotes = format.LazyQuotes
+	r.TrimLeadingSpace = format.TrimLeadingSpace
+	return &csvSource{
+		reader:   r,
+		file:     f,
+		input:    input,
+		Size:     info.Size(),
+		noHeader: format.NoHeader,
+		skipped:  skipped,
+	}, nil
+}
+
+func (s *csvSource) Close() error {
+	return s.file.Close()
+}
+
+// Read returns the next data record
+func (s *csvSource) Read() ([]string, error) {
+	if s.pending != nil {
+		record := s.pending
+		s.pending = nil
+		s.line = s.pendingLine
+		return record, nil
+	}
+	record, err := s.reader.Read()
+	if err == nil {
+		line, _ := s.reader.FieldPos(0)
+		s.line = line + s.skipped
+	}
+	return record, err
+}
+
+// Line is the line in the file where the last record read starts
+func (s *csvSource) Line() int {
+	return s.line
+}
+
+// ParseErrorLine is the line in the file where a record that failed to
+// parse starts
+func (s *csvSource) ParseErrorLine(err *csv.ParseError) int {
+	return err.StartLine + s.skipped
+}
+
+// ReadHeader returns the column names. For a headerless file they are taken
+// from names, then made up as col_1 to col_n, and the first record is kept
+// for Read.
+func (s *csvSource) ReadHeader(names []string) ([]string, error) {
+	record, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(record) > 0 {
+		// A BOM that survived decoding, e.g. from a UTF-16 file
+		record[0] = strings.TrimPrefix(record[0], "\ufeff")
+	}
+	if !s.noHeader {
+		return record, nil
+	}
+
+	s.pending, s.pendingLine = record, s.line
+	headers := make([]string, len(record))
+	for i := range headers {
+		if i < len(names) && names[i] != "" {
+			headers[i] = names[i]
+		} else {
+			headers[i] = fmt.Sprintf("col_%d", i+1)
+		}
+	}
+	return headers, nil
+}
+
+// quoteRewriter turns fields quoted with another character, or quotes
+// escaped with a backslash, into the doubled-quote form encoding/csv reads.
+// Line breaks are kept, so line numbers stay the same. With another quote
+// character a " is plain text, so unquoted fields holding one are quoted.
+type quoteRewriter struct {
+	in               *bufio.Reader
+	quote            rune
+	escape           rune
+	comma            rune
+	comment          rune
+	trimLeadingSpace bool
+
+	inQuotes   bool
+	fieldStart bool
+	lineStart  bool
+	inComment  bool
+	// bare holds the unquoted field being read when the quote isn't ",
+	// until it is known whether it needs quoting.
+	bare []byte
+	out  []byte
+	err  error
+}
+
+func newQuoteRewriter(in *bufio.Reader, format CSVFormat) *quoteRewriter {
+	q := &quoteRewriter{
+		in:               in,
+		quote:            format.Quote,
+		escape:           format.Escape,
+		comma:            format.Delimiter,
+		comment:          format.Comment,
+		trimLeadingSpace: format.TrimLeadingSpace,
+		fieldStart:       true,
+		lineStart:        true,
+	}
+	if q.quote == 0 {
+		q.quote = '"'
+	}
+	if q.comma == 0 {
+		q.comma = ','
+	}
+	return q
+}
+
+func (q *quoteRewriter) Read(p []byte) (int, error) {
+	for len(q.out) == 0 {
+		if q.err != nil {
+			return 0, q.err
+		}
+		q.step()
+	}
+	n := copy(p, q.out)
+	q.out = q.out[n:]
+	return n, nil
+}
+
+func (q *quoteRewriter) emit(r rune) {
+	q.out = utf8.AppendRune(q.out, r)
+}
+
+// emitLiteral writes a character that belongs to a quoted field's value
+func (q *quoteRewriter) emitLiteral(r rune) {
+	if r == '"' {
+		q.out = append(q.out, '"', '"')
+		return
+	}
+	q.emit(r)
+}
+
+// flushBare writes the unquoted field read so far, quoted when it holds a
+// ". A carriage return ending the line stays outside the quotes.
+func (q *quoteRewriter) flushBare(lineEnd bool) {
+	field := q.bare
+	q.bare = q.bare[:0]
+	cr := lineEnd && bytes.HasSuffix(field, []byte("\r"))
+	if cr {
+		field = field[:len(field)-1]
+	}
+	if bytes.IndexByte(field, '"') >= 0 {
+		q.out = append(q.out, '"')
+		q.out = append(q.out, bytes.ReplaceAll(field, []byte(`"`), []byte(`""`))...)
+		q.out = append(q.out, '"')
+	} else {
+		q.out = append(q.out, field...)
+	}
+	if cr {
+		q.out = append(q.out, '\r')
+	}
+}
+
+func (q *quoteRewriter) step() {
+	r, _, err := q.in.ReadRune()
+	if err != nil {
+		q.flushBare(true)
+		q.err = err
+		return
+	}
+
+	if !q.inQuotes {
+		switch {
+		case q.inComment || (q.lineStart && q.comment != 0 && r == q.comment):
+			q.inComment = r != '\n'
+		case r == q.quote && q.fieldStart:
+			q.out = append(q.out, '"')
+			q.inQuotes = true
+			q.fieldStart, q.lineStart = false, false
+			return
+		}
+		switch {
+		case r == q.comma || r == '\n':
+			q.flushBare(r == '\n')
+			q.emit(r)
+		case q.quote != '"' && !q.inComment && !(q.fieldStart && q.trimLeadingSpace && (r == ' ' || r == '\t')):
+			q.bare = utf8.AppendRune(q.bare, r)
+		default:
+			q.emit(r)
+		}
+		q.lineStart = r == '\n'
+		q.fieldStart = r == q.comma || r == '\n' || (q.fieldStart && q.trimLeadingSpace && (r == ' ' || r == '\t'))
+		return
+	}
+
+	switch {
+	case r == q.escape && q.escape != q.quote:
+		next, _, err := q.in.ReadRune()
+		if err != nil {
+			q.emitLiteral(r)
+			return
+		}
+		q.emitLiteral(next)
+	case r == q.quote:
+		next, _, err := q.in.ReadRune()
+		if err == nil && next == q.quote {
+			q.emitLiteral(q.quote)
+			return
+		}
+		if err == nil {
+			_ = q.in.UnreadRune()
+		}
+		q.out = append(q.out, '"')
+		q.inQuotes = false
+	default:
+		q.emitLiteral(r)
+	}
+}
@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectDelimiter(t *testing.T) {
+	cases := []struct {
+		name      string
+		sample    string
+		truncated bool
+		want      rune
+	}{
+		{"commas", "a,b,c\n1,2,3\n", false, ','},
+		{"semicolons", "a;b\n1,5;2\n", false, ';'},
+		{"tabs", "a\tb\n1\t2\n", false, '\t'},
+		{"pipes over inconsistent commas", "a,b|c|d\n1,2|3|4\n1|2,3,4|5\n", false, '|'},
+		{"quoted text skipped", "\"x;y;z\",b\n1,2\n", false, ','},
+		{"CRLF line ends", "a;b\r\n1;2\r\n", false, ';'},
+		{"blank lines skipped", "a|b\n\n1|2\n", false, '|'},
+		{"single column", "name\nx\n", false, ','},
+		{"whole last line counted", "a;b,c;d\n1;2,3;4\n5;6,7", false, ','},
+		{"truncated last line ignored", "a;b,c;d\n1;2,3;4\n5;6,7", true, ';'},
+	}
+	for _, c := range cases {
+		if got := detectDelimiter(c.sample, '"', c.truncated); got != c.want {
+			t.Errorf("%s: detectDelimiter(%q) = %q, want %q", c.name, c.sample, got, c.want)
+		}
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	cases := []struct {
+		name      string
+		sample    string
+		truncated bool
+		want      string
+	}{
+		{"UTF-8 BOM", "\xEF\xBB\xBFid\n", false, EncodingUTF8},
+		{"UTF-16LE BOM", "\xFF\xFEi\x00", false, "utf-16le"},
+		{"UTF-16BE BOM", "\xFE\xFF\x00i", false, "utf-16be"},
+		{"ASCII", "id,name\n", false, EncodingUTF8},
+		{"UTF-8", "şehir,café\n", false, EncodingUTF8},
+		{"UTF-8 cut mid character", "caf\xC3", true, EncodingUTF8},
+		{"cut character in a whole file", "caf\xC3", false, "windows-1252"},
+		{"Turkish letters", "\xFEehir,\xFDsim\n", false, "windows-1254"},
+		{"Western letters", "caf\xE9\n", false, "windows-1252"},
+	}
+	for _, c := range cases {
+		if got := detectEncoding([]byte(c.sample), c.truncated); got != c.want {
+			t.Errorf("%s: detectEncoding(%q) = %s, want %s", c.name, c.sample, got, c.want)
+		}
+	}
+}
+
+func TestCSVSourceHeader(t *testing.T) {
+	type record struct {
+		line   int
+		fields []string
+	}
+	cases := []struct {
+		name    string
+		content string
+		format  CSVFormat
+		names   []string
+		want    []string
+		records []record
+	}{
+		{
+			name:    "header row",
+			content: "id,name\n1,a\n2,b\n",
+			want:    []string{"id", "name"},
+			records: []record{{2, []string{"1", "a"}}, {3, []string{"2", "b"}}},
+		},
+		{
+			name:    "no header",
+			content: "1,a\n2,b\n",
+			format:  CSVFormat{NoHeader: true},
+			want:    []string{"col_1", "col_2"},
+			records: []record{{1, []string{"1", "a"}}, {2, []string{"2", "b"}}},
+		},
+		{
+			name:    "no header with names from a schema",
+			content: "1,a,x\n",
+			format:  CSVFormat{NoHeader: true},
+			names:   []string{"id", "", "note"},
+			want:    []string{"id", "col_2", "note"},
+			records: []record{{1, []string{"1", "a", "x"}}},
+		},
+		{
+			name:    "fewer names than columns",
+			content: "1,a\n",
+			format:  CSVFormat{NoHeader: true},
+			names:   []string{"id"},
+			want:    []string{"id", "col_2"},
+			records: []record{{1, []string{"1", "a"}}},
+		},
+		{
+			name:    "skipped lines before the header",
+			content: "Report\nJanuary\nid,name\n1,a\n",
+			format:  CSVFormat{SkipLines: 2},
+			want:    []string{"id", "name"},
+			records: []record{{4, []string{"1", "a"}}},
+		},
+		{
+			name:    "skipped lines without a header",
+			content: "Report\n1,a\n2,b\n",
+			format:  CSVFormat{SkipLines: 1, NoHeader: true},
+			want:    []string{"col_1", "col_2"},
+			records: []record{{2, []string{"1", "a"}}, {3, []string{"2", "b"}}},
+		},
+		{
+			name:    "BOM and single quotes",
+			content: "\ufeffid,name\n1,'a,b'\n2,5\" pipe\n",
+			format:  CSVFormat{Quote: '\''},
+			want:    []string{"id", "name"},
+			records: []record{{2, []string{"1", "a,b"}}, {3, []string{"2", `5" pipe`}}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "t.csv")
+			if err := os.WriteFile(path, []byte(c.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c.format.Encoding = EncodingUTF8
+			s, err := openCSV(path, c.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			headers, err := s.ReadHeader(c.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(headers, c.want) {
+				t.Errorf("headers = %q, want %q", headers, c.want)
+			}
+			var got []record
+			for {
+				fields, err := s.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, record{s.Line(), fields})
+			}
+			if !reflect.DeepEqual(got, c.records) {
+				t.Errorf("records = %v, want %v", got, c.records)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// Failing rows listed per file; the rest are only counted
+const maxReportedFailures = 100
+
+// RowFailure is a row that would not load. Column and Value are empty when
+// the row as a whole is broken.
+type RowFailure struct {
+	Line   int
+	Column string
+	Value  string
+	Reason string
+}
+
+func (f RowFailure) String() string {
+	if f.Column == "" {
+		return fmt.Sprintf("line %d: %s", f.Line, f.Reason)
+	}
+	return fmt.Sprintf("line %d: %s = %q: %s", f.Line, f.Column, f.Value, f.Reason)
+}
+
+// DryRunResult is what importing one file would do
+type DryRunResult struct {
+	FileName string
+	Table    string
+	DDL      string
+	Rows     int
+	Failed   int
+	// Ragged summarises the rows the ragged row policies padded, cut or
+	// rejected.
+	Ragged string
+	// Failures holds the first failing rows, up to maxReportedFailures.
+	Failures []RowFailure
+	Err      error
+}
+
+// DryRun goes through every plan as an import would, reading and converting
+// each row, but only reports the DDL, row counts and the rows that would
+// fail. It never connects to the database. The error says how many files
+// would not import cleanly.
+func DryRun(plans []*FilePlan, dbType string, opts Options, reporter Reporter) ([]DryRunResult, error) {
+	dialect, err := db.DialectFor(dbType)
+	if err != nil {
+		return nil, err
+	}
+	reporter.Log(fmt.Sprintf("Dry run for %s: nothing will be written to the database", dialect.Name()))
+
+	results := make([]DryRunResult, len(plans))
+	unclean := 0
+	for i, plan := range plans {
+		reporter.FileStart(plan.FileName, i, len(plans))
+		result := DryRunResult{FileName: plan.FileName, Err: plan.Err}
+		if plan.Err == nil {
+			result = dryRunPlan(dialect, plan, opts, reporter)
+		}
+		results[i] = result
+
+		if result.Err == nil && result.Failed > 0 {
+			result.Err = fmt.Errorf("%d of %d rows would fail", result.Failed, result.Rows)
+		}
+		if result.Err != nil {
+			unclean++
+		}
+		reporter.FileEnd(plan.FileName, result.Err)
+	}
+
+	if unclean > 0 {
+		return results, fmt.Errorf("dry run: %d file(s) would not import cleanly", unclean)
+	}
+	return results, nil
+}
+
+func dryRunPlan(dialect db.Dialect, plan *FilePlan, opts Options, reporter Reporter) DryRunResult {
+	schema := plan.Schema
+	result := DryRunResult{FileName: plan.FileName, Table: schema.Table, DDL: GenerateCreateTableSQL(dialect, schema)}
+	logPlan(plan, reporter)
+	reporter.Log("  " + result.DDL)
+
+	result.Rows, result.Ragged, result.Err = validateRows(plan, opts, func(f RowFailure) {
+		result.Failed++
+		if len(result.Failures) < maxReportedFailures {
+			result.Failures = append(result.Failures, f)
+		}
+	})
+
+	reporter.Log(fmt.Sprintf("  %s: %d rows, %d would load, %d would fail", schema.Table, result.Rows, result.Rows-result.Failed, result.Failed))
+	if result.Ragged != "" {
+		reporter.Log(fmt.Sprintf("  %s: %s", schema.Table, result.Ragged))
+	}
+	for _, f := range result.Failures {
+		reporter.Log("    " + f.String())
+	}
+	if more := result.Failed - len(result.Failures); more > 0 {
+		reporter.Log(fmt.Sprintf("    ...and %d more", more))
+	}
+	return result
+}
+
+// validateRows reads every data row of the plan's file, calls fail for each
+// one that would not load and returns how many rows there were, along with
+// what the ragged row policies did. Rows the policies reject or abort on
+// are failures; the dry run carries on past them.
+func validateRows(plan *FilePlan, opts Options, fail func(RowFailure)) (int, string, error) {
+	r, err := openCSV(plan.Path, plan.Format)
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+
+	if _, err := r.ReadHeader(nil); err != nil {
+		return 0, "", fmt.Errorf("reading header: %w", err)
+	}
+
+	schema := plan.Schema
+	nulls := columnNullSets(schema.Columns, opts.NullTokens)
+	ragged := newRaggedRows(len(schema.Headers), opts)
+	rows := 0
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, ragged.String(), nil
+		}
+		rows++
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return rows, ragged.String(), err
+			}
+			fail(RowFailure{Line: r.ParseErrorLine(parseErr), Reason: parseErr.Err.Error()})
+			continue
+		}
+		line := r.Line()
+		record, rejected, err := ragged.fit(record)
+		if err != nil {
+			reason := err.Error()
+			if !rejected {
+				reason += ", which stops the import"
+			}
+			fail(RowFailure{Line: line, Reason: reason})
+			continue
+		}
+		for i, col := range schema.Columns {
+			// Fields missing from a padded row are NULL
+			v, present := "", schema.Fields[i] < len(record)
+			if present {
+				v = record[schema.Fields[i]]
+			}
+			if !present || isNullValue(nulls[i], v) {
+				if col.NotNull && col.Default == nil {
+					fail(RowFailure{Line: line, Column: col.Name, Value: v, Reason: "null in a NOT NULL column"})
+					break
+				}
+				continue
+			}
+			if err := validateField(col, v); err != nil {
+				fail(RowFailure{Line: line, Column: col.Name, Value: v, Reason: err.Error()})
+				break
+			}
+		}
+	}
+}
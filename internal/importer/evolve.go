@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// evolveTable fits the plan to the existing table it is loaded into.
+// Columns the table lacks are added when evolve is set and fail the file
+// otherwise, and table columns too narrow for the file are widened where no
+// value is lost. Columns the file lacks are left out of the INSERTs, so
+// they are loaded as NULL or their default. The returned plan spells
+// column names as the table does; altered is whether the table changed.
+func evolveTable(conn execer, dialect db.Dialect, plan *FilePlan, evolve bool, reporter Reporter) (evolved *FilePlan, altered bool, err error) {
+	schema := plan.Schema
+	table := schema.Table
+	existing, err := db.TableColumns(conn, dialect, table)
+	if err != nil {
+		return plan, false, fmt.Errorf("reading the columns of %s: %w", table, err)
+	}
+
+	columns := append([]ColumnReport(nil), schema.Columns...)
+	mergeKeys := append([]string(nil), schema.MergeKeys...)
+	matched := make(map[string]bool, len(existing))
+	var added []ColumnReport
+	for i := range columns {
+		col := &columns[i]
+		found := findColumn(existing, col.Name)
+		if found == nil {
+			added = append(added, *col)
+			continue
+		}
+		matched[found.Name] = true
+		if found.Name != col.Name {
+			for k, key := range mergeKeys {
+				if key == col.Name {
+					mergeKeys[k] = found.Name
+				}
+			}
+			col.Name = found.Name
+		}
+		if evolve {
+			widened, err := widenColumn(conn, dialect, table, *found, *col, reporter)
+			if err != nil {
+				return plan, altered, err
+			}
+			altered = altered || widened
+		}
+	}
+
+	if len(added) > 0 && !evolve {
+		names := make([]string, len(added))
+		for i, col := range added {
+			names[i] = col.Name
+		}
+		return plan, altered, fmt.Errorf("table %s has no column %s; turn on schema evolution to add them", table, strings.Join(names, ", "))
+	}
+	for _, col := range added {
+		// Added as nullable, the rows already there have no value
+		typ := createType(dialect, schema, col)
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", dialect.QuoteIdent(table), dialect.QuoteIdent(col.Name), typ)
+		if _, err := conn.Exec(stmt); err != nil {
+			return plan, altered, fmt.Errorf("adding column %s to %s: %w", col.Name, table, err)
+		}
+		altered = true
+		reporter.Log(fmt.Sprintf("Added column %s %s to %s", col.Name, typ, table))
+	}
+
+	for _, c := range existing {
+		if matched[c.Name] {
+			continue
+		}
+		if !c.Nullable && !c.HasDefault {
+			reporter.Log(fmt.Sprintf("Column %s of %s is not in the file and is NOT NULL without a default, so rows will be refused", c.Name, table))
+		} else {
+			reporter.Log(fmt.Sprintf("Column %s of %s is not in the file, loading it as NULL or its default", c.Name, table))
+		}
+	}
+
+	fitted := *plan
+	fitted.Schema.Columns, fitted.Schema.MergeKeys = columns, mergeKeys
+	return &fitted, altered, nil
+}
+
+// findColumn looks name up among columns, exactly and then ignoring case
+// as MySQL and unquoted PostgreSQL names do
+func findColumn(columns []db.Column, name string) *db.Column {
+	for i := range columns {
+		if columns[i].Name == name {
+			return &columns[i]
+		}
+	}
+	for i := range columns {
+		if strings.EqualFold(columns[i].Name, name) {
+			return &columns[i]
+		}
+	}
+	return nil
+}
+
+// widenColumn changes existing to a type that also holds the file's values
+// of col, when that loses none of the values already in it. Other
+// mismatches are only reported; rows that do not fit are refused.
+func widenColumn(conn execer, dialect db.Dialect, table string, existing db.Column, col ColumnReport, reporter Reporter) (bool, error) {
+	fits := holds(existing.Type, col.Type)
+	if col.Type == db.TypeDecimal && col.Precision > 0 {
+		// The file's decimals may have fewer integer digits or places than
+		// the column's values already in the table
+		digits, scale := integerDigits(existing.Type), 0
+		if existing.Type == db.TypeDecimal && existing.Precision > 0 {
+			digits, scale = existing.Precision-existing.Scale, existing.Scale
+		}
+		if col.Scale < scale {
+			col.Precision, col.Scale = col.Precision+scale-col.Scale, scale
+		}
+		if col.Precision-col.Scale < digits {
+			col.Precision = digits + col.Scale
+		}
+	}
+	if existing.Type == db.TypeDecimal && col.Type == db.TypeDecimal {
+		fits = existing.Precision == 0 ||
+			(col.Precision > 0 && existing.Scale >= col.Scale && existing.Precision-existing.Scale >= col.Precision-col.Scale)
+	}
+	want := sqlType(dialect, col)
+	if existing.Type == "" || strings.EqualFold(existing.SQLType, want) || fits {
+		return false, nil
+	}
+	if !widens(existing.Type, col.Type) {
+		reporter.Log(fmt.Sprintf("Column %s of %s is %s but the file has %s values; rows that do not fit will be refused",
+			existing.Name, table, existing.SQLType, col.TypeName()))
+		return false, nil
+	}
+	stmt := dialect.AlterColumnType(table, existing, want)
+	if stmt == "" {
+		reporter.Log(fmt.Sprintf("Column %s of %s is %s but the file has %s values; %s cannot widen it here",
+			existing.Name, table, existing.SQLType, col.TypeName(), dialect.Name()))
+		return false, nil
+	}
+	if _, err := conn.Exec(stmt); err != nil {
+		return false, fmt.Errorf("widening column %s of %s: %w", existing.Name, table, err)
+	}
+	reporter.Log(fmt.Sprintf("Widened column %s of %s from %s to %s", existing.Name, table, existing.SQLType, want))
+	return true, nil
+}
+
+// holds reports whether a column of type have takes every value of type
+// want unchanged. Decimals also need the precision and scale to compare.
+func holds(have db.ColumnType, want db.ColumnType) bool {
+	switch have {
+	case want, db.TypeText:
+		return true
+	case db.TypeBigInt:
+		return want == db.TypeInt
+	case db.TypeDecimal:
+		return want == db.TypeInt || want == db.TypeBigInt
+	case db.TypeFloat:
+		return want == db.TypeInt
+	case db.TypeTimestamp, db.TypeTimestampTZ:
+		return want == db.TypeDate
+	}
+	return false
+}
+
+// integerDigits is how many digits a value of an integer type can have
+func integerDigits(t db.ColumnType) int {
+	switch t {
+	case db.TypeInt:
+		return 10
+	case db.TypeBigInt:
+		return 19
+	}
+	return 0
+}
+
+// widens reports whether a column of type have can become want without
+// changing any value already in it
+func widens(have db.ColumnType, want db.ColumnType) bool {
+	switch have {
+	case db.TypeInt:
+		return want == db.TypeBigInt || want == db.TypeDecimal || want == db.TypeFloat
+	case db.TypeBigInt, db.TypeDecimal:
+		return want == db.TypeDecimal
+	case db.TypeDate:
+		return want == db.TypeTimestamp
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// recordingConn keeps the statements executed on it; nothing else is
+// expected to be called
+type recordingConn struct {
+	execer
+	statements []string
+}
+
+func (c *recordingConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	c.statements = append(c.statements, query)
+	return nil, nil
+}
+
+func TestWidenColumn(t *testing.T) {
+	decimal := func(precision, scale int) ColumnReport {
+		return ColumnReport{Name: "v", Type: db.TypeDecimal, Precision: precision, Scale: scale}
+	}
+	column := func(sqlType string) db.Column {
+		c := db.Column{Name: "v", SQLType: sqlType, Nullable: true}
+		c.Type = db.ColumnType(sqlType)
+		return c
+	}
+	numeric := func(sqlType string, precision, scale int) db.Column {
+		return db.Column{Name: "v", SQLType: sqlType, Type: db.TypeDecimal, Nullable: true, Precision: precision, Scale: scale}
+	}
+	cases := []struct {
+		name     string
+		dialect  db.Dialect
+		existing db.Column
+		col      ColumnReport
+		// want is the statement issued, or "" for none
+		want string
+	}{
+		{
+			name:     "more digits and places",
+			dialect:  db.PostgresDialect{},
+			existing: numeric("numeric(10,2)", 10, 2),
+			col:      decimal(20, 4),
+			want:     `ALTER TABLE "t" ALTER COLUMN "v" TYPE NUMERIC(20,4) USING "v"::NUMERIC(20,4)`,
+		},
+		{
+			name:     "more places keep the integer digits",
+			dialect:  db.MySQLDialect{},
+			existing: numeric("decimal(5,2)", 5, 2),
+			col:      decimal(12, 4),
+			want:     "ALTER TABLE `t` MODIFY COLUMN `v` DECIMAL(12,4)",
+		},
+		{
+			name:     "more digits keep the places",
+			dialect:  db.PostgresDialect{},
+			existing: numeric("numeric(14,4)", 14, 4),
+			col:      decimal(14, 2),
+			want:     `ALTER TABLE "t" ALTER COLUMN "v" TYPE NUMERIC(16,4) USING "v"::NUMERIC(16,4)`,
+		},
+		{
+			name:     "narrower decimals fit",
+			dialect:  db.PostgresDialect{},
+			existing: numeric("numeric(20,4)", 20, 4),
+			col:      decimal(12, 2),
+		},
+		{
+			name:     "unconstrained numeric takes anything",
+			dialect:  db.PostgresDialect{},
+			existing: numeric("numeric", 0, 0),
+			col:      decimal(30, 10),
+		},
+		{
+			name:     "bigint keeps every integer digit",
+			dialect:  db.PostgresDialect{},
+			existing: column("bigint"),
+			col:      decimal(12, 2),
+			want:     `ALTER TABLE "t" ALTER COLUMN "v" TYPE NUMERIC(21,2) USING "v"::NUMERIC(21,2)`,
+		},
+		{
+			name:     "integer to bigint",
+			dialect:  db.PostgresDialect{},
+			existing: column("int"),
+			col:      ColumnReport{Name: "v", Type: db.TypeBigInt},
+			want:     `ALTER TABLE "t" ALTER COLUMN "v" TYPE BIGINT USING "v"::BIGINT`,
+		},
+		{
+			name:     "text holds anything",
+			dialect:  db.PostgresDialect{},
+			existing: column("string"),
+			col:      decimal(12, 2),
+		},
+		{
+			name:     "decimals never narrow to integers",
+			dialect:  db.PostgresDialect{},
+			existing: column("decimal"),
+			col:      ColumnReport{Name: "v", Type: db.TypeInt},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn := &recordingConn{}
+			altered, err := widenColumn(conn, c.dialect, "t", c.existing, c.col, NopReporter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if len(conn.statements) > 0 {
+				got = conn.statements[0]
+			}
+			if got != c.want || altered != (c.want != "") || len(conn.statements) > 1 {
+				t.Errorf("widenColumn ran %q (altered %v), want %q", conn.statements, altered, c.want)
+			}
+		})
+	}
+}
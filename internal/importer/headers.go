@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// HeaderOptions control how CSV headers are turned into column names.
+// Headers are always trimmed, stripped of quotes and control characters,
+// cut to the engine's length limit and made unique; blank headers become
+// col_1 to col_n by position.
+type HeaderOptions struct {
+	// SnakeCase lowercases names and joins words with underscores, so
+	// "Order ID" and "orderId" both become order_id.
+	SnakeCase bool
+	// Transliterate replaces Turkish letters with their ASCII look-alikes,
+	// e.g. "Müşteri Adı" becomes "Musteri Adi".
+	Transliterate bool
+	// AvoidReserved appends an underscore to reserved words such as order
+	// or select, so the column can be queried without quoting.
+	AvoidReserved bool
+}
+
+// HeaderRename records a header that was changed to make a column name
+type HeaderRename struct {
+	Header  string
+	Name    string
+	Reasons []string
+}
+
+func (r HeaderRename) String() string {
+	return fmt.Sprintf("%q -> %q (%s)", r.Header, r.Name, strings.Join(r.Reasons, ", "))
+}
+
+var turkishASCII = strings.NewReplacer(
+	"ç", "c", "Ç", "C", "ğ", "g", "Ğ", "G", "ı", "i", "İ", "I",
+	"ö", "o", "Ö", "O", "ş", "s", "Ş", "S", "ü", "u", "Ü", "U",
+)
+
+// normalizeHeaders returns a usable column name for every header, and the
+// headers that had to change. dialect may be nil, which skips the length
+// and reserved word checks.
+func normalizeHeaders(headers []string, dialect db.Dialect, opts HeaderOptions) ([]string, []HeaderRename) {
+	names := make([]string, len(headers))
+	reasons := make([][]string, len(headers))
+	maxLen := 0
+	if dialect != nil {
+		maxLen = dialect.MaxIdentifierLength()
+	}
+
+	for i, h := range headers {
+		name, why := normalizeHeader(h, opts)
+		if name == "" {
+			name, why = fmt.Sprintf("col_%d", i+1), []string{"blank header"}
+		}
+		if opts.AvoidReserved && dialect != nil && dialect.IsReserved(name) {
+			name += "_"
+			why = append(why, "reserved word")
+		}
+		if cut := truncateIdentifier(name, maxLen); cut != name {
+			name = cut
+			why = append(why, fmt.Sprintf("longer than %d bytes", maxLen))
+		}
+		names[i], reasons[i] = name, why
+	}
+
+	// Engines compare column names without case, so "ID" and "id" clash.
+	// Suffixes skip every name in the file so they never take one that a
+	// later header would have used.
+	taken := make(map[string]bool, len(names))
+	for _, n := range names {
+		taken[strings.ToLower(n)] = true
+	}
+	used := make(map[string]bool, len(names))
+	for i, n := range names {
+		if !used[strings.ToLower(n)] {
+			used[strings.ToLower(n)] = true
+			continue
+		}
+		for k := 2; ; k++ {
+			suffix := fmt.Sprintf("_%d", k)
+			candidate := truncateIdentifier(n, maxLen-len(suffix)) + suffix
+			if maxLen == 0 {
+				candidate = n + suffix
+			}
+			key := strings.ToLower(candidate)
+			if !taken[key] && !used[key] {
+				names[i] = candidate
+				used[key] = true
+				reasons[i] = append(reasons[i], "duplicate")
+				break
+			}
+		}
+	}
+
+	var renames []HeaderRename
+	for i, h := range headers {
+		if names[i] != h {
+			renames = append(renames, HeaderRename{Header: h, Name: names[i], Reasons: reasons[i]})
+		}
+	}
+	return names, renames
+}
+
+// normalizeHeader applies the per-name rules and says which ones changed h
+func normalizeHeader(h string, opts HeaderOptions) (string, []string) {
+	var why []string
+	name := strings.Map(func(r rune) rune {
+		if r == '"' || r == '`' || r == '\'' || unicode.IsControl(r) || r == '\ufeff' {
+			return -1
+		}
+		return r
+	}, h)
+	if name != h {
+		why = append(why, "removed quotes or control characters")
+	}
+	if trimmed := strings.TrimSpace(name); trimmed != name {
+		name = trimmed
+		why = append(why, "trimmed")
+	}
+	if opts.Transliterate {
+		if ascii := turkishASCII.Replace(name); ascii != name {
+			name = ascii
+			why = append(why, "transliterated")
+		}
+	}
+	if opts.SnakeCase {
+		if snake := snakeCase(name); snake != name {
+			name = snake
+			why = append(why, "snake_case")
+		}
+	}
+	return name, why
+}
+
+// snakeCase lowercases s and separates words with single underscores. A
+// word starts at a space or punctuation, or where a lowercase letter or
+// digit is followed by an uppercase one.
+func snakeCase(s string) string {
+	var b strings.Builder
+	pendingSep := false
+	var prev rune
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+				pendingSep = true
+			}
+			if pendingSep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			pendingSep = false
+			if r == 'İ' {
+				// strings.ToLower would add a combining dot
+				b.WriteRune('i')
+			} else {
+				b.WriteRune(unicode.ToLower(r))
+			}
+		default:
+			pendingSep = true
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// truncateIdentifier cuts name to at most max bytes without splitting a
+// character. A max of 0 or less leaves it whole.
+func truncateIdentifier(name string, max int) string {
+	if max <= 0 || len(name) <= max {
+		return name
+	}
+	cut := name[:max]
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return cut
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+func TestNormalizeHeaders(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	cases := []struct {
+		name    string
+		headers []string
+		dialect db.Dialect
+		opts    HeaderOptions
+		want    []string
+		// wantRenamed are the headers reported as changed
+		wantRenamed []string
+	}{
+		{
+			name:    "unchanged",
+			headers: []string{"id", "name"},
+			dialect: db.PostgresDialect{},
+			want:    []string{"id", "name"},
+		},
+		{
+			name:        "blank and quoted headers",
+			headers:     []string{"", " ", `"id"`, "\ufeffname "},
+			dialect:     db.PostgresDialect{},
+			want:        []string{"col_1", "col_2", "id", "name"},
+			wantRenamed: []string{"", " ", `"id"`, "\ufeffname "},
+		},
+		{
+			name:        "duplicates differing in case",
+			headers:     []string{"id", "ID", "id_2"},
+			dialect:     db.PostgresDialect{},
+			want:        []string{"id", "ID_3", "id_2"},
+			wantRenamed: []string{"ID"},
+		},
+		{
+			name:        "duplicates after snake_case",
+			headers:     []string{"Order ID", "orderId", "order_id"},
+			dialect:     db.SQLiteDialect{},
+			opts:        HeaderOptions{SnakeCase: true},
+			want:        []string{"order_id", "order_id_2", "order_id_3"},
+			wantRenamed: []string{"Order ID", "orderId", "order_id"},
+		},
+		{
+			name:        "cut to the PostgreSQL limit",
+			headers:     []string{long},
+			dialect:     db.PostgresDialect{},
+			want:        []string{long[:63]},
+			wantRenamed: []string{long},
+		},
+		{
+			name:        "cut without splitting a character",
+			headers:     []string{strings.Repeat("ş", 40)},
+			dialect:     db.PostgresDialect{},
+			want:        []string{strings.Repeat("ş", 31)},
+			wantRenamed: []string{strings.Repeat("ş", 40)},
+		},
+		{
+			name:        "duplicates cut to make room for the suffix",
+			headers:     []string{long, long + "b"},
+			dialect:     db.PostgresDialect{},
+			want:        []string{long[:63], long[:61] + "_2"},
+			wantRenamed: []string{long, long + "b"},
+		},
+		{
+			name:    "no limit without a dialect",
+			headers: []string{long},
+			want:    []string{long},
+		},
+		{
+			name:        "reserved and Turkish words",
+			headers:     []string{"order", "Müşteri Adı"},
+			dialect:     db.MySQLDialect{},
+			opts:        HeaderOptions{SnakeCase: true, Transliterate: true, AvoidReserved: true},
+			want:        []string{"order_", "musteri_adi"},
+			wantRenamed: []string{"order", "Müşteri Adı"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			names, renames := normalizeHeaders(c.headers, c.dialect, c.opts)
+			if !reflect.DeepEqual(names, c.want) {
+				t.Errorf("names = %q, want %q", names, c.want)
+			}
+			var renamed []string
+			for _, r := range renames {
+				renamed = append(renamed, r.Header)
+			}
+			if !reflect.DeepEqual(renamed, c.wantRenamed) {
+				t.Errorf("renamed %q, want %q", renamed, c.wantRenamed)
+			}
+		})
+	}
+}
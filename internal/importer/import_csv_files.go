@@ -3,207 +3,208 @@ package importer
 import (
 	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"fyne.io/fyne/v2/widget"
 	"github.com/devakdogan/go_csv_adapter/internal/db"
 )
 
-type LoadingHandle struct {
-	stopChan      chan bool
-	animationDone chan bool
+// Options controls how ImportCSVFiles loads the files it finds.
+type Options struct {
+	// BatchSize is the number of rows per INSERT or native bulk load. For
+	// INSERTs it is lowered per table when the columns would exceed the
+	// engine's bind parameter limit, and 0 picks the largest batch that
+	// fits.
+	BatchSize int
+	Workers   int
+	// Transactional loads each file inside a single transaction, so a file
+	// that fails leaves the database as it was. Batches are then inserted
+	// one at a time, since a transaction is bound to one connection. Rows
+	// rejected within MaxErrors do not fail the file.
+	Transactional bool
+	LoadMethod    LoadMethod
+	// SampleSize is how many rows type inference looks at; 0 scans the
+	// whole file.
+	SampleSize int
+	// CSV is how the files are written; zero fields are detected per file.
+	CSV CSVFormat
+	// NullTokens are the cell values loaded as NULL. "" also matches cells
+	// that contain only whitespace.
+	NullTokens []string
+	// TemporalLayouts are the time.Parse layouts tried when looking for
+	// date and time columns; nil uses DefaultTemporalLayouts.
+	TemporalLayouts []string
+	// MonthFirst reads dates such as 03/04/2024 as March 4th when they
+	// could be either. The default is day-first.
+	MonthFirst bool
+	// ShortRows and LongRows decide what happens to rows with fewer or
+	// more fields than the header; the zero value aborts the file.
+	ShortRows RaggedPolicy
+	LongRows  RaggedPolicy
+	// MergeKeys turn on merging: rows whose values in these columns are
+	// already in the table update that row instead of being added. A
+	// unique index on them is created when needed. Schema files can set
+	// other keys per file. On MySQL text keys are VARCHAR(255), and rows
+	// with longer keys are rejected.
+	MergeKeys []string
+	// IfExists is what happens to a table that already exists, unless a
+	// schema file sets it for the file; the zero value appends.
+	IfExists ConflictStrategy
+	// EvolveSchema adds the file's new columns to a table that already
+	// exists, and widens its columns where that loses nothing, e.g. INTEGER
+	// to BIGINT. Without it a file with new columns fails.
+	EvolveSchema bool
+	// MaxErrors is how many rows of a file may be rejected, by the ragged
+	// row policies or by the database, before the file is stopped. Rows
+	// that fail are written to <file>.csv.rejected.csv either way. A
+	// negative value never stops.
+	MaxErrors int
+	// Headers controls how CSV headers become column names.
+	Headers HeaderOptions
+	// DryRun parses and validates every row and reports the DDL that would
+	// run, without connecting to the database.
+	DryRun bool
 }
 
-func StartLoadingAnimation(logOutput *widget.TextGrid, baseMessage string) *LoadingHandle {
-	stopChan := make(chan bool)
-	animationDone := make(chan bool)
-
-	go func() {
-		loadingStates := []string{".", "..", "..."}
-		currentText := logOutput.Text()
-		lastLineIndex := strings.LastIndex(currentText, "\n")
-		if lastLineIndex == -1 {
-			lastLineIndex = 0
-		} else {
-			lastLineIndex += 1
-		}
-		baseText := currentText[:lastLineIndex]
-		i := 0
-
-		for {
-			select {
-			case <-stopChan:
-				timestamp := time.Now().Format("15:04:05")
-				finalText := fmt.Sprintf("%s[%s] %s - Complete\n",
-					baseText, timestamp, baseMessage)
-				logOutput.SetText(finalText)
-				logOutput.Refresh()
-				animationDone <- true
-				return
-			default:
-				timestamp := time.Now().Format("15:04:05")
-				animationText := fmt.Sprintf("%s[%s] %s%s",
-					baseText, timestamp, baseMessage, loadingStates[i])
-				logOutput.SetText(animationText)
-				logOutput.Refresh()
-				i = (i + 1) % len(loadingStates)
-				time.Sleep(500 * time.Millisecond)
-			}
-		}
-	}()
+// execer is the part of *sql.DB and *sql.Tx the insert path needs
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-	return &LoadingHandle{
-		stopChan:      stopChan,
-		animationDone: animationDone,
+// DefaultOptions returns the settings the desktop app has always used.
+func DefaultOptions() Options {
+	return Options{
+		BatchSize:  1000,
+		Workers:    10,
+		LoadMethod: LoadAuto,
+		SampleSize: 1000,
+		NullTokens: []string{"", "NULL", `\N`},
+		ShortRows:  RaggedAbort,
+		LongRows:   RaggedAbort,
+		MaxErrors:  1000,
+		IfExists:   ConflictAppend,
 	}
 }
 
-func (h *LoadingHandle) Stop() {
-	h.stopChan <- true
-	<-h.animationDone
+func GenerateCreateTableSQL(dialect db.Dialect, schema TableSchema) string {
+	escapedTable := dialect.QuoteIdent(schema.Table)
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", escapedTable)
+	for i, col := range schema.Columns {
+		stmt += fmt.Sprintf("%s %s", dialect.QuoteIdent(col.Name), createType(dialect, schema, col))
+		if col.NotNull {
+			stmt += " NOT NULL"
+		}
+		if col.Default != nil {
+			stmt += " DEFAULT " + sqlLiteral(dialect, timeText(col, convertField(col, *col.Default)))
+		}
+		if i < len(schema.Columns)-1 {
+			stmt += ", "
+		}
+	}
+	if len(schema.PrimaryKey) > 0 {
+		keys := make([]string, len(schema.PrimaryKey))
+		for i, k := range schema.PrimaryKey {
+			keys[i] = dialect.QuoteIdent(k)
+		}
+		stmt += fmt.Sprintf(", PRIMARY KEY (%s)", strings.Join(keys, ", "))
+	}
+	stmt += ");"
+	return stmt
 }
 
-func appendLog(grid *widget.TextGrid, message string) {
-	timestamp := time.Now().Format("15:04:05")
-	logLine := fmt.Sprintf("[%s] %s\n", timestamp, message)
-
-	currentText := grid.Text()
-	grid.SetText(currentText + logLine)
-
-	grid.Refresh()
+// sqlType is the dialect's type for col, sized for decimals
+func sqlType(dialect db.Dialect, col ColumnReport) string {
+	if col.Type == db.TypeDecimal && col.Precision > 0 {
+		return dialect.MapDecimal(col.Precision, col.Scale)
+	}
+	return dialect.MapType(col.Type)
 }
 
-func createDBProvider(dbType string, config *db.DbConfig) (db.DBProvider, error) {
-	switch dbType {
-	case "PostgreSQL":
-		return &db.Postgres{Config: config.ToPostgresConfig()}, nil
-	case "MySQL":
-		return &db.MySQL{Config: config.ToMySQLConfig()}, nil
-	case "SQLite":
-		return &db.SQLite{Config: config.ToSQLiteConfig()}, nil
+// sqlLiteral writes a converted value as a SQL literal for DEFAULT clauses
+func sqlLiteral(dialect db.Dialect, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int64, float64:
+		return fmt.Sprint(v)
 	default:
-		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+		return db.QuoteLiteral(dialect, fmt.Sprint(v))
 	}
 }
 
-func readCSVHeadersAndSamples(filePath string, sampleLimit int) ([]string, [][]string, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-
-		}
-	}(f)
-
-	r := csv.NewReader(f)
-	r.FieldsPerRecord = -1
-
-	headers, err := r.Read()
-	if err != nil {
-		return nil, nil, err
-	}
+// countingReader counts the bytes read through it, so progress can be
+// measured against the file size without holding the file in memory
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	var samples [][]string
-	for i := 0; i < sampleLimit; i++ {
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			break
-		}
-		samples = append(samples, record)
-	}
-	return headers, samples, nil
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
-func inferColumnTypes(headers []string, samples [][]string) []string {
-	types := make([]string, len(headers))
+// recordReader is the part of csvSource the insert loop reads through
+type recordReader interface {
+	Read() ([]string, error)
+	Line() int
+	ParseErrorLine(err *csv.ParseError) int
+}
 
-	for _, row := range samples {
-		for i, val := range row {
-			if types[i] == "string" {
-				continue
-			}
-			if _, err := strconv.Atoi(val); err == nil {
-				types[i] = "int"
-				continue
-			}
-			if _, err := strconv.ParseFloat(val, 64); err == nil {
-				types[i] = "float"
-				continue
-			}
-			if _, err := time.Parse("2006-01-02", val); err == nil {
-				types[i] = "date"
-				continue
-			}
-			types[i] = "string"
-		}
-	}
-	return types
+// csvBatch is a slice of rows, the lines they start on and the number of
+// input bytes that were consumed to read them
+type csvBatch struct {
+	records [][]string
+	lines   []int
+	bytes   int64
 }
 
-func GenerateCreateTableSQL(tableName string, headers []string, types []string) string {
-	escapedTable := EscapeIdentifier(tableName)
-	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", escapedTable)
-	for i, col := range headers {
-		sqlType := "TEXT"
-		switch types[i] {
-		case "int":
-			sqlType = "INTEGER"
-		case "float":
-			sqlType = "REAL"
-		case "date":
-			sqlType = "DATE"
-		}
-		stmt += fmt.Sprintf("%s %s", EscapeIdentifier(col), sqlType)
-		if i < len(headers)-1 {
-			stmt += ", "
-		}
-	}
-	stmt += ");"
-	return stmt
+func newCSVBatch(size int) csvBatch {
+	return csvBatch{records: make([][]string, 0, size), lines: make([]int, 0, size)}
 }
 
-func BulkInsertCSVRecords(
-	dbConn *sql.DB,
-	tableName string,
-	headers []string,
-	records [][]string,
-	dbType string,
+// bulkInsertCSVRecords streams the remaining rows of r into the loader's
+// table. A single reader (the caller's goroutine) cuts batches of batchSize
+// rows and hands them to workerCount insert workers over a bounded channel,
+// so at most a few batches per worker are in memory at once. Progress is the
+// share of the file's size bytes covered by the batches inserted so far.
+func bulkInsertCSVRecords(
+	loader *tableLoader,
+	r recordReader,
+	input *countingReader,
+	size int64,
 	batchSize int,
 	workerCount int,
-	logOutput *widget.TextGrid,
-	updateProgress func(int, int),
+	reporter Reporter,
 ) error {
-	// Ensure we don't create more workers than needed
-	totalBatches := (len(records) + batchSize - 1) / batchSize
-	if workerCount > totalBatches {
-		workerCount = totalBatches
-	}
 	if workerCount <= 0 {
 		workerCount = 1
 	}
+	if batchSize <= 0 {
+		batchSize = 1
+	}
 
 	var wg sync.WaitGroup
-	tasks := make(chan [][]string, workerCount*2) // Buffer channel to avoid blocking
-	errChan := make(chan error, workerCount)
-	total := len(records)
-	progress := make([]int, workerCount)
+	tasks := make(chan csvBatch, workerCount) // Bounded so the reader can't run ahead of the workers
+	var completed int64
+	failures := 0
 	progressLock := sync.Mutex{}
 
 	// Initialize progress to 0%
-	updateProgress(0, 0)
+	reporter.TableProgress(loader.table, 0)
 
 	// Create workers
 	for i := 0; i < workerCount; i++ {
@@ -214,253 +215,350 @@ func BulkInsertCSVRecords(
 				// Add a small delay to avoid database overload
 				time.Sleep(10 * time.Millisecond)
 
-				if err := insertBatch(dbConn, tableName, headers, batch, dbType); err != nil {
-					errChan <- fmt.Errorf("worker %d: %v", workerID, err)
-					appendLog(logOutput, fmt.Sprintf("Worker-%02d error: %v", workerID, err))
-				} else {
+				if loader.overLimit() {
+					// Too many rows were rejected; drain what is left
+					continue
+				}
+				if err := loader.load(batch.records, batch.lines); err != nil {
+					reporter.Error(fmt.Errorf("worker-%02d: %v", workerID, err))
 					progressLock.Lock()
-					progress[workerID-1] += len(batch)
-					// Calculate total progress across all workers
-					totalProcessed := 0
-					for _, p := range progress {
-						totalProcessed += p
-					}
-					percent := int(float64(totalProcessed) / float64(total) * 100)
-					if percent > 100 {
-						percent = 100
-					}
-					// Use 0 as workerID since we're only updating a single progress bar
-					updateProgress(0, percent)
+					failures++
 					progressLock.Unlock()
+					continue
+				}
+
+				progressLock.Lock()
+				completed += batch.bytes
+				percent := 100
+				if size > 0 {
+					percent = int(completed * 100 / size)
 				}
+				if percent > 100 {
+					percent = 100
+				}
+				reporter.TableProgress(loader.table, percent)
+				progressLock.Unlock()
 			}
 		}(i + 1)
 	}
 
-	// Distribute tasks to workers
-	// Send batches to workers
-	for i := 0; i < len(records); i += batchSize {
-		endIndex := i + batchSize
-		if endIndex > len(records) {
-			endIndex = len(records)
+	// Read the file and send batches to workers
+	batch := newCSVBatch(batchSize)
+	var cut int64
+	var readErr error
+	for !loader.overLimit() {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
 		}
-		tasks <- records[i:endIndex]
-	}
-
-	close(tasks)
-	wg.Wait()
-	close(errChan)
-
-	// Check for errors
-	var errs []error
-	for err := range errChan {
 		if err != nil {
-			errs = append(errs, err)
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				readErr = fmt.Errorf("reading row: %w", err)
+				break
+			}
+			// The reader carries on after a malformed row
+			if err := loader.reject(r.ParseErrorLine(parseErr), parseErr.Err, nil); err != nil {
+				readErr = err
+				break
+			}
+			continue
+		}
+		fitted, rejected, err := loader.ragged.fit(record)
+		if err != nil {
+			if !rejected {
+				readErr = fmt.Errorf("line %d: %w", r.Line(), err)
+				break
+			}
+			if err := loader.reject(r.Line(), err, record); err != nil {
+				readErr = err
+				break
+			}
+			continue
+		}
+		batch.records = append(batch.records, fitted)
+		batch.lines = append(batch.lines, r.Line())
+		if len(batch.records) == batchSize {
+			batch.bytes = input.n - cut
+			cut = input.n
+			tasks <- batch
+			batch = newCSVBatch(batchSize)
 		}
 	}
-
-	if len(errs) > 0 {
-		errMsg := fmt.Sprintf("%d errors occurred during import", len(errs))
-		appendLog(logOutput, errMsg)
-		return fmt.Errorf("%s", errMsg)
+	if len(batch.records) > 0 || input.n > cut {
+		batch.bytes = input.n - cut
+		tasks <- batch
 	}
 
-	// Set progress bar to 100% when complete
-	updateProgress(0, 100)
+	close(tasks)
+	wg.Wait()
 
-	return nil
-}
-func insertBatch(dbConn *sql.DB, tableName string, headers []string, records [][]string, dbType string) error {
-	if len(records) == 0 {
-		return nil
+	if readErr != nil {
+		return readErr
 	}
-
-	escapedTable := EscapeIdentifier(tableName)
-	escapedCols := make([]string, len(headers))
-	for i, h := range headers {
-		escapedCols[i] = EscapeIdentifier(h)
+	if loader.overLimit() {
+		return fmt.Errorf("stopped after more than %d rejected rows", loader.maxErrors)
 	}
-
-	var placeholders []string
-	var args []interface{}
-	argIndex := 1
-
-	for _, record := range records {
-		phs := make([]string, len(record))
-		for j, val := range record {
-			ph := "?"
-			if dbType == "PostgreSQL" {
-				ph = fmt.Sprintf("$%d", argIndex)
-				argIndex++
-			}
-			phs[j] = ph
-			args = append(args, val)
-		}
-		placeholders = append(placeholders, fmt.Sprintf("(%s)", strings.Join(phs, ", ")))
+	if failures > 0 {
+		return fmt.Errorf("%d errors occurred during import", failures)
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
-		escapedTable,
-		strings.Join(escapedCols, ", "),
-		strings.Join(placeholders, ", "))
+	// Set progress to 100% when complete
+	reporter.TableProgress(loader.table, 100)
 
-	_, err := dbConn.Exec(query, args...)
-	return err
+	return nil
 }
 
-func EscapeIdentifier(s string) string {
-	return fmt.Sprintf("\"%s\"", s)
+// ImportCSVFiles creates a table for every CSV file in folderPath and loads
+// its rows into it. Files that fail are reported and skipped; the returned
+// error reports a failed connection or how many files could not be imported.
+func ImportCSVFiles(folderPath string, dbType string, config db.DbConfig, opts Options, reporter Reporter) error {
+	plans, err := PlanImport(folderPath, dbType, opts)
+	if err != nil {
+		reporter.Error(err)
+		return err
+	}
+	return ExecutePlans(plans, dbType, config, opts, reporter)
 }
 
-func ImportCSVFiles(folderPath string, dbType string, config *db.DbConfig, logOutput *widget.TextGrid, updateProgress func(int, int)) { // Start loading animation in a separate goroutine
-	stopAnimation := make(chan bool)
-	animationDone := make(chan bool)
-
-	dbConnection := StartLoadingAnimation(logOutput, fmt.Sprintf("Connecting to %s database", dbType))
-
-	// Attempt to create database provider
-	provider, err := createDBProvider(dbType, config)
-
-	// Small delay to show animation
-	time.Sleep(1 * time.Second)
-
-	// Stop animation and wait for it to complete properly
-	dbConnection.Stop()
+// ExecutePlans connects to the database and imports every plan, after any
+// edits made to them. Plans that failed while planning are reported as
+// failed files. With opts.DryRun nothing is connected to; see DryRun.
+func ExecutePlans(plans []*FilePlan, dbType string, config db.DbConfig, opts Options, reporter Reporter) error {
+	if opts.DryRun {
+		_, err := DryRun(plans, dbType, opts, reporter)
+		return err
+	}
 
-	// Continue with normal import process
+	phase := fmt.Sprintf("Connecting to %s database", dbType)
+	reporter.PhaseStart(phase)
+	provider, err := db.NewProvider(dbType, config)
+	reporter.PhaseEnd(phase, err)
 	if err != nil {
-		appendLog(logOutput, fmt.Sprintf("Error creating database provider: %v", err))
-		return
+		return err
 	}
 
-	// Connecting to database with loading animation
-	// Start another loading animation for connection
-	go func() {
-		loadingStates := []string{".", "..", "..."}
-		baseMessage := "Establishing database connection"
-
-		// Save the current log text
-		currentText := logOutput.Text()
-		lastLineIndex := strings.LastIndex(currentText, "\n")
-		if lastLineIndex == -1 {
-			lastLineIndex = 0
-		} else {
-			lastLineIndex += 1
-		}
-		baseText := currentText[:lastLineIndex]
-
-		i := 0
-		for {
-			select {
-			case <-stopAnimation:
-				// Important: Add a new line after animation stops
-				timestamp := time.Now().Format("15:04:05")
-				finalText := fmt.Sprintf("%s[%s] %s - Complete\n",
-					baseText, timestamp, baseMessage)
-				logOutput.SetText(finalText)
-				logOutput.Refresh()
-				animationDone <- true
-				return
-			default:
-				timestamp := time.Now().Format("15:04:05")
-				animationText := fmt.Sprintf("%s[%s] %s%s",
-					baseText, timestamp, baseMessage, loadingStates[i])
-
-				logOutput.SetText(animationText)
-				logOutput.Refresh()
-
-				i = (i + 1) % len(loadingStates)
-				time.Sleep(500 * time.Millisecond)
-			}
-		}
-	}()
-
-	// Connect to database
+	phase = "Establishing database connection"
+	reporter.PhaseStart(phase)
 	dbConn, err := provider.Connect()
-
-	// Small delay to show animation
-	time.Sleep(2 * time.Second)
-
-	// Stop animation and wait for it to complete properly
-	stopAnimation <- true
-	<-animationDone
-
+	reporter.PhaseEnd(phase, err)
 	if err != nil {
-		appendLog(logOutput, fmt.Sprintf("DB connection failed: %v", err))
-		return
+		return err
 	}
 
-	appendLog(logOutput, "Database connection established successfully!")
+	reporter.Log("Database connection established successfully!")
 
 	defer func(dbConn *sql.DB) {
 		err := dbConn.Close()
 		if err != nil {
-			appendLog(logOutput, fmt.Sprintf("Error closing database connection: %v", err))
+			reporter.Error(fmt.Errorf("closing database connection: %w", err))
 		}
 	}(dbConn)
 
-	files, err := os.ReadDir(folderPath)
-	if err != nil {
-		appendLog(logOutput, fmt.Sprintf("Error reading folder: %v", err))
-		return
+	failed := 0
+	for i, plan := range plans {
+		reporter.FileStart(plan.FileName, i, len(plans))
+		err := plan.Err
+		if err == nil {
+			err = importPlan(dbConn, provider.Dialect(), plan, opts, reporter)
+		}
+		if err != nil {
+			failed++
+		}
+		reporter.FileEnd(plan.FileName, err)
 	}
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".csv") {
-			continue
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) could not be imported", failed)
+	}
+	return nil
+}
+
+// logPlan reports the schema file and column decisions of a plan
+func logPlan(plan *FilePlan, reporter Reporter) {
+	schema := plan.Schema
+	reporter.Log(fmt.Sprintf("Reading %s with %s", plan.FileName, plan.Format))
+	if plan.SchemaPath != "" {
+		reporter.Log(fmt.Sprintf("Using schema from %s", filepath.Base(plan.SchemaPath)))
+	}
+	if plan.IfExists != ConflictAppend {
+		reporter.Log(fmt.Sprintf("If %s exists: %s", plan.Schema.Table, plan.IfExists))
+	}
+	if len(schema.MergeKeys) > 0 {
+		reporter.Log(fmt.Sprintf("Merging on %s", strings.Join(schema.MergeKeys, ", ")))
+	}
+	for _, r := range plan.Renames {
+		reporter.Log(fmt.Sprintf("  Renamed header %s", r))
+	}
+	for i, col := range schema.Columns {
+		name := col.Name
+		if source := schema.Headers[schema.Fields[i]]; source != name {
+			name = fmt.Sprintf("%s (from %s)", name, source)
+		}
+		reporter.Log(fmt.Sprintf("  %s.%s: %s", schema.Table, name, col))
+		if len(col.Ambiguous) > 0 {
+			reporter.Log(fmt.Sprintf("  Warning: %s.%s could be day-first or month-first, reading it as %q", schema.Table, col.Name, col.Layout))
 		}
+	}
+}
 
-		filePath := filepath.Join(folderPath, file.Name())
-		appendLog(logOutput, fmt.Sprintf("Processing file: %s", file.Name()))
+func importPlan(dbConn *sql.DB, dialect db.Dialect, plan *FilePlan, opts Options, reporter Reporter) error {
+	logPlan(plan, reporter)
+	plan, existed, err := resolveTable(dbConn, dialect, plan, reporter)
+	if err != nil {
+		return err
+	}
+	tableName := plan.Schema.Table
 
-		headers, samples, err := readCSVHeadersAndSamples(filePath, 10)
+	if !opts.Transactional {
+		err := emptyTable(dbConn, dialect, plan, existed, false, reporter)
+		if err == nil {
+			plan, _, err = defineTable(dbConn, dialect, plan, existed, opts, reporter)
+		}
+		if err == nil {
+			err = loadCSVFile(dbConn, dialect, plan, opts.Workers, opts, reporter)
+		}
 		if err != nil {
-			appendLog(logOutput, fmt.Sprintf("Error reading CSV: %v", err))
-			continue
+			return err
 		}
+		reporter.Log(fmt.Sprintf("Imported into table: %s", tableName))
+		return nil
+	}
 
-		types := inferColumnTypes(headers, samples)
-
-		rawName := strings.TrimSuffix(file.Name(), ".csv")
-		tableName := fmt.Sprintf("%s", rawName)
-
-		// CREATE TABLE
-		createSQL := GenerateCreateTableSQL(tableName, headers, types)
-		_, err = dbConn.Exec(createSQL)
+	// MySQL commits any DDL implicitly, which would end the transaction
+	// and commit every row loaded so far. There the table is defined before
+	// the transaction starts and only its rows are rolled back.
+	ddlFirst := dialect.Name() == db.MySQLType
+	var altered bool
+	if ddlFirst {
+		if plan, altered, err = defineTable(dbConn, dialect, plan, existed, opts, reporter); err != nil {
+			reporter.Log(rollbackNote(dbConn, dialect, plan, existed, altered))
+			return err
+		}
+	}
+	tx, err := dbConn.Begin()
+	if err != nil {
+		if ddlFirst {
+			reporter.Log(rollbackNote(dbConn, dialect, plan, existed, altered))
+		}
+		return fmt.Errorf("starting transaction for %s: %w", tableName, err)
+	}
+	err = emptyTable(tx, dialect, plan, existed, true, reporter)
+	if err == nil && !ddlFirst {
+		plan, _, err = defineTable(tx, dialect, plan, existed, opts, reporter)
+	}
+	if err == nil {
+		err = loadCSVFile(tx, dialect, plan, 1, opts, reporter)
+	}
+	if err == nil {
+		err = tx.Commit()
 		if err != nil {
-			appendLog(logOutput, fmt.Sprintf("Error creating table %s: %v", tableName, err))
-			continue
+			err = fmt.Errorf("committing %s: %w", tableName, err)
+		}
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		if !ddlFirst {
+			reporter.Log(fmt.Sprintf("Rolled back %s, the table was left unchanged", tableName))
+		} else {
+			reporter.Log(rollbackNote(dbConn, dialect, plan, existed, altered))
 		}
+		return err
+	}
+	reporter.Log(fmt.Sprintf("Imported into table: %s", tableName))
+	return nil
+}
 
-		f, err := os.Open(filePath)
-		if err != nil {
-			appendLog(logOutput, fmt.Sprintf("Error reopening CSV: %v", err))
-			continue
+// rollbackNote undoes what can be undone of a failed transactional import
+// on MySQL, where the table's DDL was committed before loading, and says
+// what was left. A table the import created is dropped again.
+func rollbackNote(conn *sql.DB, dialect db.Dialect, plan *FilePlan, existed bool, altered bool) string {
+	table := plan.Schema.Table
+	switch {
+	case existed && plan.IfExists == ConflictReplace:
+		return fmt.Sprintf("Rolled back %s, but MySQL had already committed dropping the old table", table)
+	case !existed:
+		if _, err := conn.Exec("DROP TABLE IF EXISTS " + dialect.QuoteIdent(table)); err != nil {
+			return fmt.Sprintf("Rolled back %s, but could not drop the table created for it: %v", table, err)
 		}
-		defer f.Close()
+		return fmt.Sprintf("Rolled back %s and dropped the table created for it", table)
+	case altered:
+		return fmt.Sprintf("Rolled back the rows of %s, but MySQL had already committed the new columns, widened types or index", table)
+	}
+	return fmt.Sprintf("Rolled back %s, the table was left unchanged", table)
+}
 
-		r := csv.NewReader(f)
-		r.FieldsPerRecord = -1
-		_, _ = r.Read()
+// loadCSVFile streams the plan's file into its table, which defineTable
+// has set up
+func loadCSVFile(conn execer, dialect db.Dialect, plan *FilePlan, workers int, opts Options, reporter Reporter) error {
+	schema := plan.Schema
+	tableName := schema.Table
 
-		var records [][]string
-		for {
-			record, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				appendLog(logOutput, fmt.Sprintf("Error reading row: %v", err))
-				break
-			}
-			records = append(records, record)
+	r, err := openCSV(plan.Path, plan.Format)
+	if err != nil {
+		return fmt.Errorf("reopening CSV %s: %w", plan.FileName, err)
+	}
+	defer r.Close()
+	_, _ = r.ReadHeader(nil)
+
+	columns := schema.ColumnNames()
+
+	rejects, err := newRejectWriter(plan.Path, schema.Headers)
+	if err != nil {
+		return fmt.Errorf("clearing rejected rows of %s: %w", plan.FileName, err)
+	}
+	defer func() {
+		if err := rejects.Close(); err != nil {
+			reporter.Error(fmt.Errorf("writing rejected rows of %s: %w", plan.FileName, err))
 		}
+	}()
 
-		err = BulkInsertCSVRecords(dbConn, tableName, headers, records, dbType, 1000, 10, logOutput, updateProgress)
-		if err != nil {
-			appendLog(logOutput, fmt.Sprintf("Insert error for %s: %v", tableName, err))
-		} else {
-			appendLog(logOutput, fmt.Sprintf("Imported into table: %s", tableName))
+	loader := newTableLoader(conn, dialect, tableName, columns, newRowConverter(schema, opts.NullTokens, dialect), opts.LoadMethod, reporter)
+	loader.ragged, loader.rejects = newRaggedRows(len(schema.Headers), opts), rejects
+	loader.maxErrors = opts.MaxErrors
+	var before int64
+	if len(schema.MergeKeys) > 0 {
+		prepareMerge(dialect, schema, loader, reporter)
+		// Batches that run side by side could update the same row in any
+		// order, so the last row of the file would not reliably win
+		workers = 1
+		if before, err = countRows(conn, dialect, tableName); err != nil {
+			return fmt.Errorf("counting rows of %s: %w", tableName, err)
+		}
+	}
+	// Only INSERTs have a bind parameter limit. A native loader takes the
+	// batch size asked for, and cuts it into INSERTs if it falls back.
+	loader.insertLimit = effectiveBatchSize(opts.BatchSize, dialect, len(columns))
+	batchSize := loader.insertLimit
+	if loader.native != nil && opts.BatchSize > 0 {
+		batchSize = opts.BatchSize
+	} else if opts.BatchSize > 0 && batchSize < opts.BatchSize {
+		reporter.Log(fmt.Sprintf("Batch size for %s lowered to %d rows: %d columns, %s allows %d parameters per statement",
+			tableName, batchSize, len(columns), dialect.Name(), dialect.MaxParams()))
+	}
+	err = bulkInsertCSVRecords(loader, r, r.input, r.Size, batchSize, workers, reporter)
+	if len(schema.MergeKeys) > 0 {
+		// The drivers report no split between inserted and updated rows, so
+		// inserts are what the row count grew by. Rows that already held
+		// the same values are counted as updated.
+		if after, cerr := countRows(conn, dialect, tableName); cerr == nil {
+			inserted := after - before
+			counts := "%s: %d rows inserted, %d updated"
+			if !opts.Transactional {
+				// Rows other sessions wrote meanwhile are in the count too
+				counts = "%s: about %d rows inserted, %d updated (estimated from the table's row count)"
+			}
+			reporter.Log(fmt.Sprintf(counts, tableName, inserted, loader.loaded.Load()-inserted))
 		}
 	}
+	if summary := loader.ragged.String(); summary != "" {
+		reporter.Log(fmt.Sprintf("%s: %s", tableName, summary))
+	}
+	if n := rejects.Count(); n > 0 {
+		reporter.Log(fmt.Sprintf("%s: %d rows written to %s", tableName, n, filepath.Base(rejects.path)))
+	}
+	if err != nil {
+		return fmt.Errorf("inserting into %s: %w", tableName, err)
+	}
+	return nil
 }
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// TestRollbackNote runs the MySQL clean-up against SQLite, which takes the
+// same backquoted DROP TABLE
+func TestRollbackNote(t *testing.T) {
+	cases := []struct {
+		name     string
+		existed  bool
+		ifExists ConflictStrategy
+		altered  bool
+		want     string
+		// wantTable is whether table t is left behind
+		wantTable bool
+	}{
+		{name: "created table dropped", want: "Rolled back t and dropped the table created for it"},
+		{name: "replaced table gone", existed: true, ifExists: ConflictReplace, want: "already committed dropping the old table", wantTable: true},
+		{name: "altered table kept", existed: true, altered: true, want: "already committed the new columns", wantTable: true},
+		{name: "unchanged table kept", existed: true, want: "Rolled back t, the table was left unchanged", wantTable: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			provider, err := db.NewProvider(db.SQLiteType, db.DbConfig{Database: filepath.Join(t.TempDir(), "t.db")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := provider.Connect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := conn.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+				t.Fatal(err)
+			}
+
+			plan := &FilePlan{Schema: TableSchema{Table: "t"}, IfExists: c.ifExists}
+			if got := rollbackNote(conn, db.MySQLDialect{}, plan, c.existed, c.altered); !strings.Contains(got, c.want) {
+				t.Errorf("rollbackNote = %q, want it to contain %q", got, c.want)
+			}
+			exists, err := db.TableExists(conn, db.SQLiteDialect{}, "t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != c.wantTable {
+				t.Errorf("table exists: %v, want %v", exists, c.wantTable)
+			}
+		})
+	}
+}
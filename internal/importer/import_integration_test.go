@@ -0,0 +1,544 @@
+package importer_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+	"github.com/devakdogan/go_csv_adapter/internal/importer"
+)
+
+// importFixture imports testdata/<name> into a fresh SQLite file and returns
+// an open connection to it along with everything that was reported.
+func importFixture(t *testing.T, name string, opts importer.Options) (*sql.DB, *importer.Recorder, error) {
+	t.Helper()
+
+	config := db.DbConfig{Database: filepath.Join(t.TempDir(), "import.db")}
+	recorder := &importer.Recorder{}
+	importErr := importer.ImportCSVFiles(filepath.Join("testdata", name), db.SQLiteType, config, opts, recorder)
+
+	conn := connectSQLite(t, config)
+	t.Cleanup(func() { conn.Close() })
+	return conn, recorder, importErr
+}
+
+func connectSQLite(t *testing.T, config db.DbConfig) *sql.DB {
+	t.Helper()
+	provider, err := db.NewProvider(db.SQLiteType, config)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	conn, err := provider.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return conn
+}
+
+func countRows(t *testing.T, conn *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM "` + table + `"`).Scan(&n); err != nil {
+		t.Fatalf("counting %s: %v", table, err)
+	}
+	return n
+}
+
+func TestImportCSVFilesSQLite(t *testing.T) {
+	opts := importer.DefaultOptions()
+	opts.BatchSize = 2
+	opts.Workers = 3
+
+	conn, recorder, err := importFixture(t, "basic", opts)
+	if err != nil {
+		t.Fatalf("ImportCSVFiles: %v", err)
+	}
+	if errs := recorder.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected reported errors: %v", errs)
+	}
+
+	for table, want := range map[string]int{"customers": 5, "orders": 7} {
+		if got := countRows(t, conn, table); got != want {
+			t.Errorf("%s: got %d rows, want %d", table, got, want)
+		}
+	}
+
+	var tables int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 2 {
+		t.Errorf("got %d tables, want 2 (non-CSV files must be ignored)", tables)
+	}
+
+	var name string
+	if err := conn.QueryRow(`SELECT "name" FROM "customers" WHERE "id" = 5`).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Doe, Jane" {
+		t.Errorf("quoted field: got %q", name)
+	}
+	var product string
+	if err := conn.QueryRow(`SELECT "product" FROM "orders" WHERE "order_id" = 103`).Scan(&product); err != nil {
+		t.Fatal(err)
+	}
+	if product != `Cable "USB-C"` {
+		t.Errorf("escaped quotes: got %q", product)
+	}
+
+	finished := map[string]bool{}
+	for _, e := range recorder.Events() {
+		if e.Kind == importer.EventTableProgress && e.Percent == 100 {
+			finished[e.Name] = true
+		}
+	}
+	if !finished["customers"] || !finished["orders"] {
+		t.Errorf("progress did not reach 100%% for every table: %v", finished)
+	}
+}
+
+func TestImportCSVFilesMissingFolder(t *testing.T) {
+	config := db.DbConfig{Database: filepath.Join(t.TempDir(), "import.db")}
+	err := importer.ImportCSVFiles(filepath.Join("testdata", "does-not-exist"), db.SQLiteType, config,
+		importer.DefaultOptions(), importer.NopReporter{})
+	if err == nil {
+		t.Fatal("expected an error for a missing folder")
+	}
+}
+
+func TestDryRunSQLite(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"t.csv":             "id,n\n1,5\n2,x\n3,7\n",
+		"t.csv.schema.yaml": "columns:\n  n: {type: int}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := db.DbConfig{Database: filepath.Join(t.TempDir(), "import.db")}
+	opts := importer.DefaultOptions()
+	opts.DryRun = true
+
+	recorder := &importer.Recorder{}
+	err := importer.ImportCSVFiles(dir, db.SQLiteType, config, opts, recorder)
+	if err == nil || !strings.Contains(err.Error(), "1 file(s) would not import cleanly") {
+		t.Errorf("got error %v, want the file reported as unclean", err)
+	}
+	for _, want := range []string{`CREATE TABLE IF NOT EXISTS "t"`, "t: 3 rows, 2 would load, 1 would fail", `line 3: n = "x"`} {
+		found := false
+		for _, e := range recorder.Events() {
+			found = found || (e.Kind == importer.EventLog && strings.Contains(e.Message, want))
+		}
+		if !found {
+			t.Errorf("no log message containing %q", want)
+		}
+	}
+
+	plans, err := importer.PlanImport(dir, db.SQLiteType, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := importer.DryRun(plans, db.SQLiteType, opts, importer.NopReporter{})
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	r := results[0]
+	if r.Table != "t" || r.Rows != 3 || r.Failed != 1 || len(r.Failures) != 1 ||
+		r.Failures[0].Line != 3 || r.Failures[0].Column != "n" || r.Failures[0].Value != "x" {
+		t.Errorf("got %+v", r)
+	}
+
+	conn := connectSQLite(t, config)
+	defer conn.Close()
+	exists, err := db.TableExists(conn, db.SQLiteDialect{}, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("the dry run created table t")
+	}
+}
+
+// importCase imports each of runs in turn into one SQLite database. A run
+// maps file names to their contents and is written to a fresh folder, so
+// rejected rows files land next to it.
+type importCase struct {
+	name string
+	// setup are statements run on the database before the first run.
+	setup   []string
+	runs    []map[string]string
+	options func(*importer.Options)
+	// wantErr is a substring of the last run's error, or "" for success.
+	wantErr string
+	// wantRows counts the rows of table t.
+	wantRows int
+	// queries map a query returning one text value to that value.
+	queries map[string]string
+	// wantRejected are the line and fields of each row in t.csv's rejected
+	// rows file, without the error column, or nil for no file.
+	wantRejected [][]string
+	// wantLog must appear among the last run's log messages.
+	wantLog string
+}
+
+func (c importCase) run(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "import.db")
+	config := db.DbConfig{Database: dbPath}
+	opts := importer.DefaultOptions()
+	opts.BatchSize = 10
+	opts.Workers = 1
+	if c.options != nil {
+		c.options(&opts)
+	}
+
+	if len(c.setup) > 0 {
+		conn := connectSQLite(t, config)
+		for _, stmt := range c.setup {
+			if _, err := conn.Exec(stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+		conn.Close()
+	}
+
+	var dir string
+	var recorder *importer.Recorder
+	var err error
+	for _, files := range c.runs {
+		dir = t.TempDir()
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		recorder = &importer.Recorder{}
+		err = importer.ImportCSVFiles(dir, db.SQLiteType, config, opts, recorder)
+	}
+	if c.wantErr == "" && err != nil {
+		t.Fatalf("ImportCSVFiles: %v (reported %v)", err, recorder.Errors())
+	}
+	if c.wantErr != "" && (err == nil || !strings.Contains(fmt.Sprint(recorder.Errors()), c.wantErr)) {
+		t.Fatalf("got error %v (reported %v), want one containing %q", err, recorder.Errors(), c.wantErr)
+	}
+
+	conn := connectSQLite(t, config)
+	defer conn.Close()
+
+	if c.wantErr == "" {
+		if got := countRows(t, conn, "t"); got != c.wantRows {
+			t.Errorf("got %d rows, want %d", got, c.wantRows)
+		}
+	}
+	for query, want := range c.queries {
+		var got string
+		if err := conn.QueryRow(query).Scan(&got); err != nil {
+			t.Errorf("%s: %v", query, err)
+		} else if got != want {
+			t.Errorf("%s: got %q, want %q", query, got, want)
+		}
+	}
+
+	rejected, err := os.ReadFile(filepath.Join(dir, "t.csv.rejected.csv"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if c.wantRejected != nil {
+			t.Errorf("no rejected rows file, want %v", c.wantRejected)
+		}
+	case err != nil:
+		t.Fatal(err)
+	default:
+		r := csv.NewReader(bytes.NewReader(rejected))
+		// Ragged rows are written as they were read
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("reading rejected rows: %v", err)
+		}
+		var got [][]string
+		for _, r := range records[1:] {
+			got = append(got, append([]string{r[0]}, r[2:]...))
+		}
+		if !reflect.DeepEqual(got, c.wantRejected) {
+			t.Errorf("rejected rows: got %v, want %v", got, c.wantRejected)
+		}
+	}
+
+	if c.wantLog != "" {
+		found := false
+		for _, e := range recorder.Events() {
+			found = found || (e.Kind == importer.EventLog && strings.Contains(e.Message, c.wantLog))
+		}
+		if !found {
+			t.Errorf("no log message containing %q", c.wantLog)
+		}
+	}
+}
+
+func TestImportPoliciesSQLite(t *testing.T) {
+	cases := []importCase{
+		{
+			name:     "short rows padded",
+			runs:     []map[string]string{{"t.csv": "id,a,b\n1,x,y\n2,z\n"}},
+			options:  func(o *importer.Options) { o.ShortRows = importer.RaggedPad },
+			wantRows: 2,
+			queries:  map[string]string{`SELECT COALESCE("b", 'NULL') FROM "t" WHERE "id" = 2`: "NULL"},
+			wantLog:  "1 short rows padded with NULL",
+		},
+		{
+			name:     "long rows truncated",
+			runs:     []map[string]string{{"t.csv": "id,a\n1,x,extra\n2,y\n"}},
+			options:  func(o *importer.Options) { o.LongRows = importer.RaggedTruncate },
+			wantRows: 2,
+			queries:  map[string]string{`SELECT "a" FROM "t" WHERE "id" = 1`: "x"},
+		},
+		{
+			name:         "ragged rows rejected",
+			runs:         []map[string]string{{"t.csv": "id,a,b\n1,x,y\n2,z\n3,p,q,r\n4,s,t\n"}},
+			options:      func(o *importer.Options) { o.ShortRows, o.LongRows = importer.RaggedReject, importer.RaggedReject },
+			wantRows:     2,
+			wantRejected: [][]string{{"3", "2", "z"}, {"4", "3", "p", "q", "r"}},
+		},
+		{
+			name:    "ragged rows abort",
+			runs:    []map[string]string{{"t.csv": "id,a\n1,x\n2\n"}},
+			wantErr: "row has 1 fields, the header has 2",
+		},
+		{
+			name: "bad rows of a batch rejected",
+			runs: []map[string]string{{
+				"t.csv":             "id,v\n1,a\n2,b\n2,dup\n3,c\n4,d\n4,dup\n5,e\n",
+				"t.csv.schema.yaml": "primary_key: [id]\n",
+			}},
+			wantRows:     5,
+			queries:      map[string]string{`SELECT "v" FROM "t" WHERE "id" = 4`: "d"},
+			wantRejected: [][]string{{"4", "2", "dup"}, {"7", "4", "dup"}},
+			wantLog:      "a batch of 7 rows failed",
+		},
+		{
+			name: "bad rows rejected in a transaction",
+			runs: []map[string]string{{
+				"t.csv":             "id\n1\n1\n2\n",
+				"t.csv.schema.yaml": "primary_key: [id]\n",
+			}},
+			options:      func(o *importer.Options) { o.Transactional = true },
+			wantRows:     2,
+			wantRejected: [][]string{{"3", "1"}},
+		},
+		{
+			name: "bad row rolls back a transaction into an existing table",
+			runs: []map[string]string{
+				{"t.csv": "id,v\n1,a\n"},
+				{"t.csv": "id,v\n2,b\n3\n"},
+			},
+			options: func(o *importer.Options) { o.Transactional = true },
+			wantErr: "row has 1 fields, the header has 2",
+			queries: map[string]string{
+				`SELECT COUNT(*) FROM "t"`:           "1",
+				`SELECT "v" FROM "t" WHERE "id" = 1`: "a",
+			},
+			wantLog: "Rolled back t, the table was left unchanged",
+		},
+		{
+			name: "bad row rolls back a truncate",
+			runs: []map[string]string{
+				{"t.csv": "id,v\n1,a\n"},
+				{"t.csv": "id,v\n2,b\n3\n"},
+			},
+			options: func(o *importer.Options) {
+				o.Transactional = true
+				o.IfExists = importer.ConflictTruncate
+			},
+			wantErr: "row has 1 fields, the header has 2",
+			queries: map[string]string{`SELECT COUNT(*) FROM "t" WHERE "id" = 1`: "1"},
+		},
+		{
+			name:    "bad row rolls back a new table",
+			runs:    []map[string]string{{"t.csv": "id,v\n1,a\n2\n"}},
+			options: func(o *importer.Options) { o.Transactional = true },
+			wantErr: "row has 1 fields, the header has 2",
+			queries: map[string]string{`SELECT COUNT(*) FROM sqlite_master WHERE name = 't'`: "0"},
+		},
+		{
+			name: "stopped after max errors",
+			runs: []map[string]string{{
+				"t.csv":             "id\n1\n1\n1\n1\n",
+				"t.csv.schema.yaml": "primary_key: [id]\n",
+			}},
+			options:      func(o *importer.Options) { o.MaxErrors = 1 },
+			wantErr:      "stopped after more than 1 rejected rows",
+			wantRejected: [][]string{{"3", "1"}, {"4", "1"}},
+		},
+		{
+			name: "merged on a key",
+			runs: []map[string]string{
+				{"t.csv": "id,v\n1,a\n2,b\n"},
+				{"t.csv": "id,v\n2,bb\n3,c\n3,cc\n"},
+			},
+			options:  func(o *importer.Options) { o.MergeKeys = []string{"id"} },
+			wantRows: 3,
+			queries: map[string]string{
+				`SELECT "v" FROM "t" WHERE "id" = 2`: "bb",
+				`SELECT "v" FROM "t" WHERE "id" = 3`: "cc",
+			},
+			wantLog: "t: about 1 rows inserted, 2 updated (estimated from the table's row count)",
+		},
+		{
+			name: "merged on keys from the schema file",
+			runs: []map[string]string{
+				{"t.csv": "a,b,v\n1,x,old\n1,y,old\n", "t.csv.schema.yaml": "merge_keys: [a, b]\n"},
+				{"t.csv": "a,b,v\n1,y,new\n2,x,new\n", "t.csv.schema.yaml": "merge_keys: [a, b]\n"},
+			},
+			wantRows: 3,
+			queries:  map[string]string{`SELECT "v" FROM "t" WHERE "a" = 1 AND "b" = 'y'`: "new"},
+			wantLog:  "t: about 1 rows inserted, 1 updated",
+		},
+		{
+			name: "merged in a transaction",
+			runs: []map[string]string{
+				{"t.csv": "id,v\n1,a\n2,b\n"},
+				{"t.csv": "id,v\n1,a\n2,bb\n3,c\n4,d\n"},
+			},
+			options: func(o *importer.Options) {
+				o.MergeKeys = []string{"id"}
+				o.Transactional = true
+			},
+			wantRows: 4,
+			queries:  map[string]string{`SELECT "v" FROM "t" WHERE "id" = 2`: "bb"},
+			wantLog:  "t: 2 rows inserted, 2 updated",
+		},
+		{
+			name:    "merge key not in the file",
+			runs:    []map[string]string{{"t.csv": "id,v\n1,a\n"}},
+			options: func(o *importer.Options) { o.MergeKeys = []string{"nope"} },
+			wantErr: `merge key column "nope" is not in the table`,
+		},
+		{
+			name: "column added to an existing table",
+			runs: []map[string]string{
+				{"t.csv": "id\n1\n"},
+				{"t.csv": "id,extra\n2,x\n"},
+			},
+			options:  func(o *importer.Options) { o.EvolveSchema = true },
+			wantRows: 2,
+			queries: map[string]string{
+				`SELECT "extra" FROM "t" WHERE "id" = 2`:         "x",
+				`SELECT COUNT(*) FROM "t" WHERE "extra" IS NULL`: "1",
+			},
+			wantLog: "Added column extra",
+		},
+		{
+			name: "new column refused without schema evolution",
+			runs: []map[string]string{
+				{"t.csv": "id\n1\n"},
+				{"t.csv": "id,extra\n2,x\n"},
+			},
+			wantErr: "table t has no column extra; turn on schema evolution",
+		},
+		{
+			name: "column missing from the file loaded as NULL",
+			runs: []map[string]string{
+				{"t.csv": "id,extra\n1,x\n"},
+				{"t.csv": "id\n2\n"},
+			},
+			options:  func(o *importer.Options) { o.EvolveSchema = true },
+			wantRows: 2,
+			queries:  map[string]string{`SELECT COUNT(*) FROM "t" WHERE "extra" IS NULL`: "1"},
+			wantLog:  "Column extra of t is not in the file, loading it as NULL or its default",
+		},
+		{
+			name:     "decimal column narrower than the file",
+			setup:    []string{`CREATE TABLE t (id INTEGER, v NUMERIC(5,2))`, `INSERT INTO t VALUES (1, 123.45)`},
+			runs:     []map[string]string{{"t.csv": "id,v\n2,1.2345\n"}},
+			options:  func(o *importer.Options) { o.EvolveSchema = true },
+			wantRows: 2,
+			queries:  map[string]string{`SELECT "v" FROM "t" WHERE "id" = 2`: "1.2345"},
+			wantLog:  "Column v of t is NUMERIC(5,2) but the file has decimal(14,4) values; SQLite cannot widen it here",
+		},
+		{
+			name: "existing table truncated",
+			runs: []map[string]string{
+				{"t.csv": "id\n1\n2\n"},
+				{"t.csv": "id\n3\n"},
+			},
+			options:  func(o *importer.Options) { o.IfExists = importer.ConflictTruncate },
+			wantRows: 1,
+			queries:  map[string]string{`SELECT "id" FROM "t"`: "3"},
+			wantLog:  "Table t exists, emptying it",
+		},
+		{
+			name: "existing table replaced",
+			runs: []map[string]string{
+				{"t.csv": "id,v\n1,a\n"},
+				{"t.csv": "id,w\n2,x\n"},
+			},
+			options:  func(o *importer.Options) { o.IfExists = importer.ConflictReplace },
+			wantRows: 1,
+			queries:  map[string]string{`SELECT "w" FROM "t" WHERE "id" = 2`: "x"},
+			wantLog:  "Table t exists, dropping and recreating it",
+		},
+		{
+			name: "existing table fails the file",
+			runs: []map[string]string{
+				{"t.csv": "id,v\n1,a\n"},
+				{"t.csv": "id,w\n2,x\n"},
+			},
+			options: func(o *importer.Options) { o.IfExists = importer.ConflictFail },
+			wantErr: "table t already exists",
+			queries: map[string]string{
+				`SELECT COUNT(*) FROM "t"`:           "1",
+				`SELECT "v" FROM "t" WHERE "id" = 1`: "a",
+			},
+		},
+		{
+			name: "existing table kept and a new one created",
+			runs: []map[string]string{
+				{"t.csv": "id\n1\n"},
+				{"t.csv": "id\n2\n3\n"},
+			},
+			options:  func(o *importer.Options) { o.IfExists = importer.ConflictCreateNew },
+			wantRows: 1,
+			queries: map[string]string{
+				`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name GLOB 't_[0-9]*_[0-9]*'`: "1",
+			},
+			wantLog: "Table t exists, importing into t_",
+		},
+		{
+			name: "columns shaped by a schema file",
+			runs: []map[string]string{{
+				"t.csv": "id,Full Name,amount,note\n1,Ann,,x\n2,Bob,2.5,y\n",
+				"t.csv.schema.yaml": `columns:
+  Full Name: {name: name}
+  amount: {type: "decimal(8,2)", nullable: false, default: "0"}
+  note: {drop: true}
+`,
+			}},
+			wantRows: 2,
+			queries: map[string]string{
+				`SELECT "name" FROM "t" WHERE "id" = 1`:                                               "Ann",
+				`SELECT "amount" FROM "t" WHERE "id" = 1`:                                             "0",
+				`SELECT "type" || ' ' || "notnull" FROM pragma_table_info('t') WHERE name = 'amount'`: "NUMERIC(8,2) 1",
+				`SELECT COUNT(*) FROM pragma_table_info('t')`:                                         "3",
+			},
+		},
+		{
+			name: "misspelt schema file key",
+			runs: []map[string]string{{
+				"t.csv":             "id\n1\n",
+				"t.csv.schema.json": `{"primary_keys": ["id"]}`,
+			}},
+			wantErr: `unknown field "primary_keys"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, c.run)
+	}
+}
@@ -0,0 +1,542 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// Number of sample values kept per column for reports and previews
+const maxExamples = 3
+
+// typeRank orders the scalar types from narrowest to widest. A column takes
+// the widest type any of its values needs: every bool is an int (1/0), every
+// int a bigint, and so on up to text, which holds anything.
+var typeRank = map[db.ColumnType]int{
+	db.TypeBool:    1,
+	db.TypeInt:     2,
+	db.TypeBigInt:  3,
+	db.TypeDecimal: 4,
+	db.TypeFloat:   5,
+	db.TypeText:    6,
+}
+
+// Decimal columns get at least this many digits before the point, so larger
+// values after the sampled rows still fit
+const minDecimalIntDigits = 10
+
+var (
+	decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.\d*|\.\d+)$`)
+	uuidPattern    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// ColumnReport is the type inference decided for one column, together with
+// the evidence it was based on.
+type ColumnReport struct {
+	Name     string
+	Type     db.ColumnType
+	Nullable bool
+	// Precision and Scale size decimal columns: total digits and digits
+	// after the point.
+	Precision int
+	Scale     int
+	// Values is the number of non-null values examined, Nulls the number of
+	// empty cells, null tokens or missing fields.
+	Values int
+	Nulls  int
+	// Counts is how many values matched each type at their narrowest.
+	Counts map[db.ColumnType]int
+	// WidenedBy is the first value that raised the column to Type.
+	WidenedBy string
+	Examples  []string
+	// Layout is the time layout values are parsed with when Type is
+	// temporal. Ambiguous lists the day-first and month-first layouts that
+	// both fitted every value, with Layout being the one picked.
+	Layout    string
+	Ambiguous []string
+	// NotNull and Default come from a schema file; inference never sets
+	// them.
+	NotNull bool
+	Default *string
+	// NullTokens replace the import's null tokens for this column when
+	// set; an empty list means no value is NULL.
+	NullTokens []string
+}
+
+func (r ColumnReport) String() string {
+	var kinds []string
+	for t, n := range r.Counts {
+		kinds = append(kinds, fmt.Sprintf("%d %s", n, t))
+	}
+	sort.Strings(kinds)
+
+	s := r.TypeName()
+	if r.Nullable {
+		s += ", nullable"
+	}
+	s += fmt.Sprintf(" (%d values", r.Values)
+	if len(kinds) > 0 {
+		s += ": " + strings.Join(kinds, ", ")
+	}
+	s += fmt.Sprintf("; %d nulls", r.Nulls)
+	if r.Layout != "" {
+		s += fmt.Sprintf("; layout %q", r.Layout)
+	}
+	if len(r.Ambiguous) > 0 {
+		s += fmt.Sprintf("; ambiguous between %s", strings.Join(r.Ambiguous, " and "))
+	}
+	if r.WidenedBy != "" {
+		s += fmt.Sprintf("; widened by %q", r.WidenedBy)
+	}
+	return s + ")"
+}
+
+// TypeName is the type as ParseColumnType reads it, e.g. decimal(12,2)
+func (r ColumnReport) TypeName() string {
+	if r.Type == db.TypeDecimal && r.Precision > 0 {
+		return fmt.Sprintf("%s(%d,%d)", r.Type, r.Precision, r.Scale)
+	}
+	return string(r.Type)
+}
+
+// parseBool recognises the spellings of a boolean value. 1 and 0 are left
+// to the integer types.
+func parseBool(v string) (bool, bool) {
+	switch strings.ToLower(v) {
+	case "true", "t", "yes":
+		return true, true
+	case "false", "f", "no":
+		return false, true
+	}
+	return false, false
+}
+
+// decimalDigits counts the significant digits of a plain number before and
+// after the point
+func decimalDigits(v string) (intDigits int, scale int) {
+	v = strings.TrimLeft(v, "+-")
+	intPart, frac, _ := strings.Cut(v, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+	return len(intPart), len(frac)
+}
+
+// isJSON accepts objects and arrays; bare JSON scalars are better served by
+// the other types
+func isJSON(v string) bool {
+	v = strings.TrimSpace(v)
+	if v == "" || (v[0] != '{' && v[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(v))
+}
+
+// classifyValue returns the narrowest scalar type that can hold v. Dates and
+// times are recognised separately, against the configured layouts.
+func classifyValue(v string) db.ColumnType {
+	if _, ok := parseBool(v); ok {
+		return db.TypeBool
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			return db.TypeInt
+		}
+		return db.TypeBigInt
+	} else if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		// Too long for 64 bits, but still an exact integer
+		return db.TypeDecimal
+	}
+	if decimalPattern.MatchString(v) {
+		return db.TypeDecimal
+	}
+	// ParseFloat also accepts "inf" and "nan", which are more likely words
+	if strings.ContainsAny(v, "0123456789") {
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return db.TypeFloat
+		}
+	}
+	if uuidPattern.MatchString(v) {
+		return db.TypeUUID
+	}
+	if isJSON(v) {
+		return db.TypeJSON
+	}
+	return db.TypeText
+}
+
+// widenType returns the narrowest type that holds values of both a and b
+func widenType(a, b db.ColumnType) db.ColumnType {
+	if a == "" {
+		return b
+	}
+	if b == "" || a == b {
+		return a
+	}
+	ra, okA := typeRank[a]
+	rb, okB := typeRank[b]
+	if !okA || !okB {
+		// Temporal, UUID and JSON types only mix with themselves
+		return db.TypeText
+	}
+	if ra >= rb {
+		return a
+	}
+	return b
+}
+
+// newNullSet builds the lookup used to recognise null cells
+func newNullSet(tokens []string) map[string]bool {
+	set := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		set[t] = true
+	}
+	return set
+}
+
+func isNullValue(nulls map[string]bool, v string) bool {
+	return nulls[v] || (nulls[""] && strings.TrimSpace(v) == "")
+}
+
+// columnNullSets returns the null lookup of each column: its own tokens
+// when it has them, the import's otherwise
+func columnNullSets(columns []ColumnReport, tokens []string) []map[string]bool {
+	shared := newNullSet(tokens)
+	sets := make([]map[string]bool, len(columns))
+	for i, col := range columns {
+		sets[i] = shared
+		if col.NullTokens != nil {
+			sets[i] = newNullSet(col.NullTokens)
+		}
+	}
+	return sets
+}
+
+// typeInferrer collects evidence row by row, so a full scan needs no more
+// memory than a sample
+type typeInferrer struct {
+	nulls      []map[string]bool
+	monthFirst bool
+	columns    []ColumnReport
+	temporal   []*temporalCandidates
+	// Widest integer part and fraction seen in each column's exact numbers
+	intDigits []int
+	scales    []int
+}
+
+// newTypeInferrer starts inference for a file. columnNulls holds the null
+// tokens of each column by position, nil where the column has none of its
+// own.
+func newTypeInferrer(headers []string, columnNulls [][]string, opts Options) *typeInferrer {
+	layouts := opts.TemporalLayouts
+	if layouts == nil {
+		layouts = DefaultTemporalLayouts
+	}
+	columns := make([]ColumnReport, len(headers))
+	temporal := make([]*temporalCandidates, len(headers))
+	for i, h := range headers {
+		columns[i] = ColumnReport{Name: h, Counts: make(map[db.ColumnType]int)}
+		if i < len(columnNulls) {
+			columns[i].NullTokens = columnNulls[i]
+		}
+		temporal[i] = newTemporalCandidates(h, layouts)
+	}
+	return &typeInferrer{
+		nulls:      columnNullSets(columns, opts.NullTokens),
+		monthFirst: opts.MonthFirst,
+		columns:    columns,
+		temporal:   temporal,
+		intDigits:  make([]int, len(headers)),
+		scales:     make([]int, len(headers)),
+	}
+}
+
+// observe adds one data row. Fields beyond the header are ignored and
+// missing fields count as nulls.
+func (t *typeInferrer) observe(record []string) {
+	for i := range t.columns {
+		col := &t.columns[i]
+		if i >= len(record) || isNullValue(t.nulls[i], record[i]) {
+			col.Nulls++
+			continue
+		}
+		col.Values++
+		if len(col.Examples) < maxExamples {
+			col.Examples = append(col.Examples, record[i])
+		}
+		// Spaces around a number or date don't make it text; convertField
+		// drops them when loading
+		v := strings.TrimSpace(record[i])
+		kind := classifyValue(v)
+		switch kind {
+		case db.TypeInt, db.TypeBigInt, db.TypeDecimal:
+			digits, scale := decimalDigits(v)
+			t.intDigits[i] = max(t.intDigits[i], digits)
+			t.scales[i] = max(t.scales[i], scale)
+		}
+		if temporal := t.temporal[i].observe(v); temporal != "" && (kind == db.TypeText || t.temporal[i].unixOnly()) {
+			col.Counts[temporal]++
+		} else {
+			col.Counts[kind]++
+		}
+		if widened := widenType(col.Type, kind); widened != col.Type {
+			if col.Type != "" {
+				col.WidenedBy = v
+			}
+			col.Type = widened
+		}
+	}
+}
+
+// reports returns the decision for every column. Columns without a single
+// value default to nullable text. A column takes a temporal type when one
+// layout parsed all of its values; numbers only count as Unix timestamps.
+func (t *typeInferrer) reports() []ColumnReport {
+	out := make([]ColumnReport, len(t.columns))
+	for i, col := range t.columns {
+		candidates := t.temporal[i]
+		if col.Values > 0 && (col.Type == db.TypeText || candidates.unixOnly()) {
+			if layout, ambiguous := candidates.choose(t.monthFirst); layout != "" {
+				col.Type = layoutKind(layout)
+				col.Layout = layout
+				col.Ambiguous = ambiguous
+				col.WidenedBy = ""
+			} else if col.Type == db.TypeText && candidates.brokenBy != "" && col.WidenedBy == "" {
+				col.WidenedBy = candidates.brokenBy
+			}
+		}
+		if col.Type == "" {
+			col.Type = db.TypeText
+		}
+		if col.Type == db.TypeDecimal {
+			col.Scale = t.scales[i]
+			col.Precision = col.Scale + max(t.intDigits[i], minDecimalIntDigits)
+		}
+		col.Nullable = col.Nulls > 0 || col.Values == 0
+		out[i] = col
+	}
+	return out
+}
+
+// inferCSVFile reads the header of filePath and infers column types from up
+// to opts.SampleSize rows, or from every row when it is 0 or less. names are
+// the column names of a headerless file. columnNulls, if set, is given the
+// header and returns each column's own null tokens, as for newTypeInferrer.
+func inferCSVFile(filePath string, format CSVFormat, names []string, columnNulls func(headers []string) [][]string, opts Options) ([]string, []ColumnReport, error) {
+	r, err := openCSV(filePath, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	headers, err := r.ReadHeader(names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var nulls [][]string
+	if columnNulls != nil {
+		nulls = columnNulls(headers)
+	}
+	inferrer := newTypeInferrer(headers, nulls, opts)
+	for i := 0; opts.SampleSize <= 0 || i < opts.SampleSize; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The import itself reports unreadable rows
+			break
+		}
+		inferrer.observe(record)
+	}
+	return headers, inferrer.reports(), nil
+}
+
+// rowConverter turns CSV fields into values to bind; see convertField.
+// Null cells become nil, or the column's default.
+type rowConverter struct {
+	nulls    []map[string]bool
+	width    int
+	fields   []int
+	columns  []ColumnReport
+	defaults []interface{}
+	// timeText binds dates and timestamps as ISO text instead of time.Time
+	timeText bool
+}
+
+func newRowConverter(schema TableSchema, nullTokens []string, dialect db.Dialect) *rowConverter {
+	c := &rowConverter{
+		nulls:    columnNullSets(schema.Columns, nullTokens),
+		width:    len(schema.Headers),
+		fields:   schema.Fields,
+		columns:  schema.Columns,
+		defaults: make([]interface{}, len(schema.Columns)),
+		timeText: !dialect.AcceptsTime(),
+	}
+	for i, col := range schema.Columns {
+		if col.Default != nil {
+			c.defaults[i] = c.value(col, *col.Default)
+		}
+	}
+	return c
+}
+
+func (c *rowConverter) value(col ColumnReport, v string) interface{} {
+	if c.timeText {
+		return timeText(col, convertField(col, v))
+	}
+	return convertField(col, v)
+}
+
+// convert maps a CSV record onto the table's columns. Records may be short,
+// when padded by the ragged row policy, and their missing fields are NULL;
+// longer records have not been fitted and are an error.
+func (c *rowConverter) convert(record []string) ([]interface{}, error) {
+	if len(record) > c.width {
+		return nil, fmt.Errorf("row has %d fields, the header has %d", len(record), c.width)
+	}
+	values := make([]interface{}, len(c.columns))
+	for i, field := range c.fields {
+		if field >= len(record) {
+			values[i] = c.defaults[i]
+			continue
+		}
+		v := record[field]
+		if isNullValue(c.nulls[i], v) {
+			values[i] = c.defaults[i]
+			continue
+		}
+		values[i] = c.value(c.columns[i], v)
+	}
+	return values, nil
+}
+
+// convertField converts one non-null value for col: bool, int64 and
+// float64 for those types, with booleans in numeric columns as 1 or 0, and
+// time.Time for dates and timestamps read with the column's layout. Times
+// of day are ISO text, since time.Time would carry a date. Decimals stay
+// text so no digits are lost. A value that doesn't convert is passed as
+// text for the database to accept or reject. Spaces around a value are
+// only kept in text columns, as inference and validateField ignore them.
+func convertField(col ColumnReport, v string) interface{} {
+	if col.Type == db.TypeText {
+		return v
+	}
+	v = strings.TrimSpace(v)
+	switch col.Type {
+	case db.TypeBool:
+		if b, ok := parseBool(v); ok {
+			return b
+		}
+	case db.TypeInt, db.TypeBigInt, db.TypeDecimal, db.TypeFloat:
+		if b, ok := parseBool(v); ok {
+			if b {
+				return int64(1)
+			}
+			return int64(0)
+		}
+		switch col.Type {
+		case db.TypeInt, db.TypeBigInt:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n
+			}
+		case db.TypeFloat:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	case db.TypeDate, db.TypeTime, db.TypeTimestamp, db.TypeTimestampTZ:
+		// Rows past the sample may not parse; the database reports those
+		if col.Layout == "" {
+			break
+		}
+		parsed, ok := parseTemporal(col.Layout, v)
+		if !ok {
+			break
+		}
+		switch col.Type {
+		case db.TypeTime:
+			return formatTemporal(col.Type, parsed)
+		case db.TypeTimestampTZ:
+			return parsed.UTC()
+		default:
+			// Keep the wall clock; drivers would otherwise shift a value
+			// that carried an offset into UTC
+			return time.Date(parsed.Year(), parsed.Month(), parsed.Day(),
+				parsed.Hour(), parsed.Minute(), parsed.Second(), parsed.Nanosecond(), time.UTC)
+		}
+	}
+	return v
+}
+
+// timeText writes a time.Time from convertField as ISO text, for engines
+// without date types and for SQL literals
+func timeText(col ColumnReport, v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return formatTemporal(col.Type, t)
+	}
+	return v
+}
+
+// validateField reports why v, a non-null value, would not load into col.
+// Temporal columns without a layout are left to the database. Like
+// convertField it ignores spaces around the value.
+func validateField(col ColumnReport, v string) error {
+	v = strings.TrimSpace(v)
+	if _, ok := parseBool(v); ok {
+		switch col.Type {
+		case db.TypeBool, db.TypeInt, db.TypeBigInt, db.TypeDecimal, db.TypeFloat, db.TypeText:
+			return nil
+		}
+	}
+	switch col.Type {
+	case db.TypeBool:
+		return fmt.Errorf("not a boolean")
+	case db.TypeInt:
+		if _, err := strconv.ParseInt(v, 10, 32); err != nil {
+			return fmt.Errorf("not a 32-bit integer")
+		}
+	case db.TypeBigInt:
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("not a 64-bit integer")
+		}
+	case db.TypeDecimal:
+		switch classifyValue(v) {
+		case db.TypeInt, db.TypeBigInt, db.TypeDecimal:
+		default:
+			return fmt.Errorf("not a decimal number")
+		}
+		if col.Precision > 0 {
+			digits, scale := decimalDigits(v)
+			if scale > col.Scale || digits > col.Precision-col.Scale {
+				return fmt.Errorf("does not fit %s", col.TypeName())
+			}
+		}
+	case db.TypeFloat:
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("not a number")
+		}
+	case db.TypeDate, db.TypeTime, db.TypeTimestamp, db.TypeTimestampTZ:
+		if col.Layout != "" {
+			if _, ok := parseTemporal(col.Layout, v); !ok {
+				return fmt.Errorf("does not match layout %q", col.Layout)
+			}
+		}
+	case db.TypeUUID:
+		if !uuidPattern.MatchString(v) {
+			return fmt.Errorf("not a UUID")
+		}
+	case db.TypeJSON:
+		if !json.Valid([]byte(v)) {
+			return fmt.Errorf("not valid JSON")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+func TestClassifyValue(t *testing.T) {
+	cases := []struct {
+		value string
+		want  db.ColumnType
+	}{
+		{"true", db.TypeBool},
+		{"No", db.TypeBool},
+		{"1", db.TypeInt},
+		{"-2147483648", db.TypeInt},
+		{"2147483648", db.TypeBigInt},
+		{"-9223372036854775808", db.TypeBigInt},
+		{"9223372036854775808", db.TypeDecimal},
+		{"12.50", db.TypeDecimal},
+		{".5", db.TypeDecimal},
+		{"1e10", db.TypeFloat},
+		{"inf", db.TypeText},
+		{"NaN", db.TypeText},
+		{"123e4567-e89b-12d3-a456-426614174000", db.TypeUUID},
+		{`{"a": 1}`, db.TypeJSON},
+		{"[1, 2]", db.TypeJSON},
+		{"{not json", db.TypeText},
+		{"hello", db.TypeText},
+	}
+	for _, c := range cases {
+		if got := classifyValue(c.value); got != c.want {
+			t.Errorf("classifyValue(%q) = %s, want %s", c.value, got, c.want)
+		}
+	}
+}
+
+func TestWidenType(t *testing.T) {
+	cases := []struct {
+		a, b db.ColumnType
+		want db.ColumnType
+	}{
+		{"", db.TypeInt, db.TypeInt},
+		{db.TypeInt, "", db.TypeInt},
+		{db.TypeDate, db.TypeDate, db.TypeDate},
+		{db.TypeBool, db.TypeInt, db.TypeInt},
+		{db.TypeBigInt, db.TypeInt, db.TypeBigInt},
+		{db.TypeInt, db.TypeDecimal, db.TypeDecimal},
+		{db.TypeDecimal, db.TypeFloat, db.TypeFloat},
+		{db.TypeFloat, db.TypeText, db.TypeText},
+		{db.TypeDate, db.TypeTimestamp, db.TypeText},
+		{db.TypeUUID, db.TypeInt, db.TypeText},
+		{db.TypeJSON, db.TypeText, db.TypeText},
+	}
+	for _, c := range cases {
+		if got := widenType(c.a, c.b); got != c.want {
+			t.Errorf("widenType(%q, %q) = %s, want %s", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestConvertField(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		col   ColumnReport
+		value string
+		want  interface{}
+	}{
+		{ColumnReport{Type: db.TypeBool}, "yes", true},
+		{ColumnReport{Type: db.TypeBool}, " F ", false},
+		{ColumnReport{Type: db.TypeInt}, "12", int64(12)},
+		{ColumnReport{Type: db.TypeInt}, " 12 ", int64(12)},
+		{ColumnReport{Type: db.TypeBigInt}, "true", int64(1)},
+		{ColumnReport{Type: db.TypeInt}, "x", "x"},
+		{ColumnReport{Type: db.TypeFloat}, "1e3", float64(1000)},
+		{ColumnReport{Type: db.TypeDecimal}, " 12.50 ", "12.50"},
+		{ColumnReport{Type: db.TypeDate, Layout: "2/1/2006"}, "04/03/2024", day},
+		{ColumnReport{Type: db.TypeTimestamp, Layout: time.RFC3339}, "2024-03-04T10:00:00+03:00", time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{ColumnReport{Type: db.TypeTimestampTZ, Layout: time.RFC3339}, "2024-03-04T10:00:00+03:00", time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC)},
+		{ColumnReport{Type: db.TypeTime, Layout: "15:04"}, "09:30", "09:30:00"},
+		{ColumnReport{Type: db.TypeDate}, "2024-03-04", "2024-03-04"},
+		{ColumnReport{Type: db.TypeText}, " 12 ", " 12 "},
+	}
+	for _, c := range cases {
+		if got := convertField(c.col, c.value); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("convertField(%s, %q) = %#v, want %#v", c.col.Type, c.value, got, c.want)
+		}
+	}
+}
+
+// TestValidateFieldAgreesWithConvert checks that the dry run accepts
+// exactly the values the load binds as typed values
+func TestValidateFieldAgreesWithConvert(t *testing.T) {
+	columns := []ColumnReport{
+		{Type: db.TypeBool},
+		{Type: db.TypeInt},
+		{Type: db.TypeBigInt},
+		{Type: db.TypeFloat},
+		{Type: db.TypeDate, Layout: "2006-01-02"},
+	}
+	values := []string{"12", " 12 ", "\t-7\n", "true", " no ", "1.5", "x", "2024-03-04", " 2024-03-04 ", ""}
+	for _, col := range columns {
+		for _, v := range values {
+			_, text := convertField(col, v).(string)
+			if valid := validateField(col, v) == nil; valid == text {
+				t.Errorf("%s %q: validateField accepts it %v, convertField binds it as text %v", col.Type, v, valid, text)
+			}
+		}
+	}
+	if err := validateField(ColumnReport{Type: db.TypeDecimal, Precision: 4, Scale: 2}, " 12.50 "); err != nil {
+		t.Errorf("padded decimal: %v", err)
+	}
+}
+
+func TestInferSpacedNumbers(t *testing.T) {
+	inferrer := newTypeInferrer([]string{"n", "s"}, nil, DefaultOptions())
+	for _, record := range [][]string{{" 12 ", " a "}, {"7", "b"}} {
+		inferrer.observe(record)
+	}
+	reports := inferrer.reports()
+	if reports[0].Type != db.TypeInt || reports[1].Type != db.TypeText {
+		t.Errorf("got %s and %s, want int and text", reports[0].Type, reports[1].Type)
+	}
+}
+
+func TestRowConverter(t *testing.T) {
+	zero := "0"
+	schema := TableSchema{
+		Headers: []string{"a", "b", "c", "d"},
+		Fields:  []int{0, 1, 2, 3},
+		Columns: []ColumnReport{
+			{Name: "a", Type: db.TypeText, NullTokens: []string{"-"}},
+			{Name: "b", Type: db.TypeText},
+			{Name: "c", Type: db.TypeInt, Default: &zero},
+			{Name: "d", Type: db.TypeDate, Layout: "2006-01-02"},
+		},
+	}
+	cases := []struct {
+		name    string
+		dialect db.Dialect
+		record  []string
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "column null tokens",
+			dialect: db.PostgresDialect{},
+			record:  []string{"-", "NULL", "", ""},
+			want:    []interface{}{nil, nil, int64(0), nil},
+		},
+		{
+			name:    "import null tokens are text in a column with its own",
+			dialect: db.PostgresDialect{},
+			record:  []string{"NULL", "-", " 5 ", "2024-03-04"},
+			want:    []interface{}{"NULL", "-", int64(5), time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "dates as text where the engine has no date type",
+			dialect: db.SQLiteDialect{},
+			record:  []string{"x", "y", "5", "2024-03-04"},
+			want:    []interface{}{"x", "y", int64(5), "2024-03-04"},
+		},
+		{
+			name:    "short row padded with defaults",
+			dialect: db.PostgresDialect{},
+			record:  []string{"x"},
+			want:    []interface{}{"x", nil, int64(0), nil},
+		},
+		{
+			name:    "long row refused",
+			dialect: db.PostgresDialect{},
+			record:  []string{"1", "2", "3", "2024-03-04", "5"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := newRowConverter(schema, []string{"", "NULL"}, c.dialect).convert(c.record)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("convert: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("convert = %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
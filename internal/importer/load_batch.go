@@ -0,0 +1,330 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// LoadMethod selects how batches of rows are sent to the database
+type LoadMethod string
+
+const (
+	// LoadAuto uses the native bulk loader when it needs no server setup
+	// (COPY on PostgreSQL) and multi-row INSERT otherwise.
+	LoadAuto LoadMethod = "auto"
+	// LoadInsert always uses multi-row INSERT statements.
+	LoadInsert LoadMethod = "insert"
+	// LoadNative uses the engine's native bulk loader whenever it has one.
+	LoadNative LoadMethod = "native"
+)
+
+// ParseLoadMethod validates a load method name from flags or settings
+func ParseLoadMethod(s string) (LoadMethod, error) {
+	switch m := LoadMethod(strings.ToLower(s)); m {
+	case LoadAuto, LoadInsert, LoadNative:
+		return m, nil
+	case "":
+		return LoadAuto, nil
+	default:
+		return "", fmt.Errorf("unknown load method %q (want auto, insert or native)", s)
+	}
+}
+
+// effectiveBatchSize returns how many rows of the given width fit in one
+// INSERT without passing the dialect's bind parameter limit. A requested size
+// of 0 or less means "as many as fit".
+func effectiveBatchSize(requested int, dialect db.Dialect, columns int) int {
+	if columns < 1 {
+		columns = 1
+	}
+	limit := dialect.MaxParams() / columns
+	if limit < 1 {
+		limit = 1
+	}
+	if requested <= 0 || requested > limit {
+		return limit
+	}
+	return requested
+}
+
+// tableLoader sends batches of rows to one table, through the dialect's
+// native bulk loader when one was chosen and INSERT statements otherwise.
+// It is shared by all insert workers of a file.
+type tableLoader struct {
+	conn     execer
+	dialect  db.Dialect
+	table    string
+	headers  []string
+	convert  *rowConverter
+	native   db.BulkLoader
+	fellBack atomic.Bool
+	reporter Reporter
+	// ragged fits rows to the header before they are batched, and rejects
+	// takes the rows that are skipped. More than maxErrors rejected rows
+	// stop the file; a negative maxErrors never does.
+	ragged    *raggedRows
+	rejects   *rejectWriter
+	maxErrors int
+	// upsert is appended to every INSERT when merging, and keyFields are
+	// the positions of the merge keys in headers. loaded counts the rows
+	// the database took.
+	upsert    string
+	keyFields []int
+	keyLength int
+	loaded    atomic.Int64
+	// insertLimit is the most rows one INSERT takes; native loads have no
+	// bind parameters and take a whole batch.
+	insertLimit int
+}
+
+// loadRow is a converted row with the CSV record and line it came from
+type loadRow struct {
+	line   int
+	record []string
+	values []interface{}
+}
+
+func newTableLoader(conn execer, dialect db.Dialect, table string, headers []string, convert *rowConverter, method LoadMethod, reporter Reporter) *tableLoader {
+	native, _ := dialect.(db.BulkLoader)
+	switch method {
+	case LoadInsert:
+		native = nil
+	case LoadNative:
+		if native == nil {
+			reporter.Log(fmt.Sprintf("%s has no native bulk loader, using INSERT", dialect.Name()))
+		}
+	default:
+		if native != nil && !native.BulkLoadByDefault() {
+			native = nil
+		}
+	}
+	return &tableLoader{
+		conn:        conn,
+		dialect:     dialect,
+		table:       table,
+		headers:     headers,
+		convert:     convert,
+		native:      native,
+		reporter:    reporter,
+		insertLimit: effectiveBatchSize(0, dialect, len(headers)),
+	}
+}
+
+// usesNative reports whether batches go through the native loader
+func (l *tableLoader) usesNative() bool {
+	return l.native != nil && !l.fellBack.Load()
+}
+
+// load sends one batch. Rows that cannot be converted, or that the database
+// refuses, are written to the rejected rows and the rest are still loaded.
+// lines holds the line each record starts on.
+func (l *tableLoader) load(records [][]string, lines []int) error {
+	rows := make([]loadRow, 0, len(records))
+	for i, record := range records {
+		values, err := l.convert.convert(record)
+		if err == nil && l.upsert != "" {
+			err = l.checkKeys(values)
+		}
+		if err != nil {
+			if err := l.reject(lines[i], err, record); err != nil {
+				return err
+			}
+			continue
+		}
+		rows = append(rows, loadRow{line: lines[i], record: record, values: values})
+	}
+	if l.upsert != "" {
+		rows = l.collapseKeys(rows)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if !l.usesNative() {
+		_, err := l.insertRows(rows)
+		return err
+	}
+
+	err := l.attempt(func() error { return l.nativeLoad(rowValues(rows)) })
+	if err == nil {
+		l.loaded.Add(int64(len(rows)))
+		return nil
+	}
+	// The batch was rolled back, so it can safely be sent again as
+	// INSERTs. If some fail a row is bad, and the native loader is kept
+	// for the next batches; if none do, the loader itself does not work
+	// here.
+	clean, insertErr := l.insertRows(rows)
+	if insertErr != nil {
+		return insertErr
+	}
+	if clean && l.fellBack.CompareAndSwap(false, true) {
+		l.reporter.Log(fmt.Sprintf("Native bulk load into %s failed, falling back to INSERT: %v", l.table, err))
+	}
+	return nil
+}
+
+// insertRows sends rows as INSERTs of up to insertLimit rows each and
+// looks for the bad rows of any that fail. clean is whether none did.
+func (l *tableLoader) insertRows(rows []loadRow) (clean bool, err error) {
+	clean = true
+	for len(rows) > 0 && !l.overLimit() {
+		n := min(len(rows), l.insertLimit)
+		batch := rows[:n]
+		rows = rows[n:]
+		err := l.insert(batch)
+		if err == nil {
+			continue
+		}
+		clean = false
+		if len(batch) > 1 {
+			l.reporter.Log(fmt.Sprintf("%s: a batch of %d rows failed, looking for the bad rows: %v", l.table, len(batch), err))
+		}
+		if err := l.isolate(batch, err); err != nil {
+			return false, err
+		}
+	}
+	return clean, nil
+}
+
+func (l *tableLoader) insert(rows []loadRow) error {
+	err := l.attempt(func() error {
+		return insertBatch(l.conn, l.dialect, l.table, l.headers, rowValues(rows), l.upsert)
+	})
+	if err == nil {
+		l.loaded.Add(int64(len(rows)))
+	}
+	return err
+}
+
+// attempt runs send so that when it fails the file's transaction is rolled
+// back to before it and can carry on, which PostgreSQL would otherwise
+// refuse
+func (l *tableLoader) attempt(send func() error) error {
+	tx, inTx := l.conn.(*sql.Tx)
+	if !inTx {
+		return send()
+	}
+	if _, err := tx.Exec("SAVEPOINT csv_batch"); err != nil {
+		return err
+	}
+	if err := send(); err != nil {
+		if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT csv_batch"); rbErr != nil {
+			return fmt.Errorf("%w (rolling back to savepoint: %v)", err, rbErr)
+		}
+		// Rolling back keeps the savepoint, and the search for bad rows
+		// would pile them up
+		if _, relErr := tx.Exec("RELEASE SAVEPOINT csv_batch"); relErr != nil {
+			return fmt.Errorf("%w (releasing savepoint: %v)", err, relErr)
+		}
+		return err
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT csv_batch")
+	return err
+}
+
+// isolate finds the rows of a failed batch that the database refuses by
+// retrying each half, down to single rows, so that every other row still
+// loads. err is why rows failed as a whole.
+func (l *tableLoader) isolate(rows []loadRow, err error) error {
+	if len(rows) == 1 {
+		return l.reject(rows[0].line, err, rows[0].record)
+	}
+	mid := len(rows) / 2
+	for _, half := range [][]loadRow{rows[:mid], rows[mid:]} {
+		if l.overLimit() {
+			// The file is being stopped, so the rest need not be looked at
+			return nil
+		}
+		if err := l.insert(half); err != nil {
+			if err := l.isolate(half, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reject writes a row that will not be loaded
+func (l *tableLoader) reject(line int, reason error, record []string) error {
+	if err := l.rejects.write(line, reason.Error(), record); err != nil {
+		return fmt.Errorf("writing rejected rows: %w", err)
+	}
+	return nil
+}
+
+// overLimit reports whether more rows were rejected than allowed
+func (l *tableLoader) overLimit() bool {
+	return l.maxErrors >= 0 && l.rejects.Count() > l.maxErrors
+}
+
+func rowValues(rows []loadRow) [][]interface{} {
+	values := make([][]interface{}, len(rows))
+	for i, r := range rows {
+		values[i] = r.values
+	}
+	return values
+}
+
+// nativeLoad runs the bulk loader in the file's transaction, or in a
+// transaction of its own when the file isn't loaded transactionally
+func (l *tableLoader) nativeLoad(rows [][]interface{}) error {
+	switch conn := l.conn.(type) {
+	case *sql.Tx:
+		return l.native.BulkLoad(conn, l.table, l.headers, rows)
+	case *sql.DB:
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		if err := l.native.BulkLoad(tx, l.table, l.headers, rows); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	default:
+		return fmt.Errorf("native bulk load needs a database connection, got %T", l.conn)
+	}
+}
+
+// insertBatch sends records as one multi-row INSERT, with suffix, such as
+// an upsert clause, appended when it isn't empty
+func insertBatch(dbConn execer, dialect db.Dialect, tableName string, headers []string, records [][]interface{}, suffix string) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	escapedTable := dialect.QuoteIdent(tableName)
+	escapedCols := make([]string, len(headers))
+	for i, h := range headers {
+		escapedCols[i] = dialect.QuoteIdent(h)
+	}
+
+	var placeholders []string
+	var args []interface{}
+	argIndex := 1
+
+	for _, record := range records {
+		phs := make([]string, len(record))
+		for j, val := range record {
+			phs[j] = dialect.Placeholder(argIndex)
+			argIndex++
+			args = append(args, val)
+		}
+		placeholders = append(placeholders, fmt.Sprintf("(%s)", strings.Join(phs, ", ")))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		escapedTable,
+		strings.Join(escapedCols, ", "),
+		strings.Join(placeholders, ", "))
+	if suffix != "" {
+		query += " " + suffix
+	}
+
+	_, err := dbConn.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+func TestEffectiveBatchSize(t *testing.T) {
+	cases := []struct {
+		requested int
+		dialect   db.Dialect
+		columns   int
+		want      int
+	}{
+		{0, db.PostgresDialect{}, 10, 6553},
+		{1000, db.PostgresDialect{}, 10, 1000},
+		{10000, db.PostgresDialect{}, 10, 6553},
+		{-1, db.MySQLDialect{}, 1, 65535},
+		{0, db.SQLiteDialect{}, 3, 10922},
+		{0, db.SQLiteDialect{}, 0, 32766},
+		{500, db.PostgresDialect{}, 100000, 1},
+	}
+	for _, c := range cases {
+		if got := effectiveBatchSize(c.requested, c.dialect, c.columns); got != c.want {
+			t.Errorf("effectiveBatchSize(%d, %s, %d) = %d, want %d", c.requested, c.dialect.Name(), c.columns, got, c.want)
+		}
+	}
+}
+
+// failingLoader is a native loader that records the batches it is given
+// and refuses them when err is set
+type failingLoader struct {
+	batches []int
+	err     error
+}
+
+func (f *failingLoader) BulkLoad(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	f.batches = append(f.batches, len(rows))
+	return f.err
+}
+
+func (f *failingLoader) BulkLoadByDefault() bool { return true }
+
+// TestNativeBatchesSkipTheInsertLimit loads more rows than one INSERT may
+// bind: the native loader gets them all at once, and falling back cuts
+// them into INSERTs that fit
+func TestNativeBatchesSkipTheInsertLimit(t *testing.T) {
+	for _, nativeErr := range []error{nil, errors.New("no bulk loading here")} {
+		provider, err := db.NewProvider(db.SQLiteType, db.DbConfig{Database: filepath.Join(t.TempDir(), "t.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := provider.Connect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+			t.Fatal(err)
+		}
+
+		dialect := db.SQLiteDialect{}
+		schema := TableSchema{Table: "t", Headers: []string{"id"}, Fields: []int{0}, Columns: []ColumnReport{{Name: "id", Type: db.TypeInt}}}
+		native := &failingLoader{err: nativeErr}
+		loader := newTableLoader(conn, dialect, "t", []string{"id"}, newRowConverter(schema, nil, dialect), LoadNative, NopReporter{})
+		loader.native, loader.maxErrors = native, -1
+
+		n := dialect.MaxParams() + 100
+		records, lines := make([][]string, n), make([]int, n)
+		for i := range records {
+			records[i], lines[i] = []string{strconv.Itoa(i)}, i+2
+		}
+		if err := loader.load(records, lines); err != nil {
+			t.Fatalf("native error %v: %v", nativeErr, err)
+		}
+
+		var rows int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM t").Scan(&rows); err != nil {
+			t.Fatal(err)
+		}
+		wantRows := 0
+		if nativeErr != nil {
+			wantRows = n
+		}
+		if len(native.batches) != 1 || native.batches[0] != n || rows != wantRows || loader.fellBack.Load() != (nativeErr != nil) {
+			t.Errorf("native error %v: native batches %v, %d rows inserted, fell back %v; want [%d], %d rows",
+				nativeErr, native.batches, rows, loader.fellBack.Load(), n, wantRows)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package importer_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+	"github.com/devakdogan/go_csv_adapter/internal/importer"
+)
+
+const benchTable = "bench_rows"
+
+// postgresBenchConfig returns the server to benchmark against. Set
+// CSV_IMPORT_BENCH_POSTGRES=1 and the usual CSV_IMPORT_HOST, _PORT, _USER,
+// _PASSWORD and _DATABASE variables to run it.
+func postgresBenchConfig(b *testing.B) db.DbConfig {
+	b.Helper()
+	if os.Getenv("CSV_IMPORT_BENCH_POSTGRES") == "" {
+		b.Skip("set CSV_IMPORT_BENCH_POSTGRES=1 to benchmark against PostgreSQL")
+	}
+	config := db.DefaultDbConfig(db.PostgreSQLType)
+	if err := config.ApplyEnv("CSV_IMPORT"); err != nil {
+		b.Fatal(err)
+	}
+	return config
+}
+
+// writeBenchCSV writes a rows x cols file of mixed integers and text
+func writeBenchCSV(b *testing.B, rows int, cols int) (string, int64) {
+	b.Helper()
+	dir := b.TempDir()
+	path := filepath.Join(dir, benchTable+".csv")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := csv.NewWriter(f)
+	record := make([]string, cols)
+	for c := range record {
+		record[c] = fmt.Sprintf("col_%d", c)
+	}
+	w.Write(record)
+	for r := 0; r < rows; r++ {
+		for c := range record {
+			if c%2 == 0 {
+				record[c] = fmt.Sprint(r * c)
+			} else {
+				record[c] = fmt.Sprintf("value %d/%d", r, c)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		b.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		b.Fatal(err)
+	}
+	f.Close()
+	return dir, info.Size()
+}
+
+func dropBenchTable(b *testing.B, config db.DbConfig) {
+	b.Helper()
+	provider, err := db.NewProvider(db.PostgreSQLType, config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	conn, err := provider.Connect()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("DROP TABLE IF EXISTS " + provider.Dialect().QuoteIdent(benchTable)); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkPostgresLoad compares multi-row INSERT with COPY FROM STDIN on
+// the same 20-column file.
+func BenchmarkPostgresLoad(b *testing.B) {
+	config := postgresBenchConfig(b)
+	dir, size := writeBenchCSV(b, 20000, 20)
+
+	for _, method := range []importer.LoadMethod{importer.LoadInsert, importer.LoadNative} {
+		b.Run(string(method), func(b *testing.B) {
+			opts := importer.DefaultOptions()
+			opts.LoadMethod = method
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				dropBenchTable(b, config)
+				b.StartTimer()
+
+				if err := importer.ImportCSVFiles(dir, db.PostgreSQLType, config, opts, importer.NopReporter{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// ParseMergeKeys splits a comma-separated list of merge key columns from
+// flags or settings
+func ParseMergeKeys(s string) []string {
+	var keys []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// createType is the type col is created with. Text merge keys are
+// VARCHAR on MySQL, so the unique index covers the whole value.
+func createType(dialect db.Dialect, schema TableSchema, col ColumnReport) string {
+	typ := sqlType(dialect, col)
+	if keyLength(dialect) == 0 || typ != dialect.MapType(db.TypeText) {
+		return typ
+	}
+	for _, key := range schema.MergeKeys {
+		if key == col.Name {
+			return fmt.Sprintf("VARCHAR(%d)", keyLength(dialect))
+		}
+	}
+	return typ
+}
+
+// keyLength is the most characters a text merge key may have, or 0 for no
+// limit
+func keyLength(dialect db.Dialect) int {
+	if dialect.Name() == db.MySQLType {
+		return db.MySQLKeyLength
+	}
+	return 0
+}
+
+// ensureMergeIndex makes sure the table has the unique index on the merge
+// keys that the upsert clauses need, unless they are its primary key.
+// created is whether the index was added.
+func ensureMergeIndex(conn execer, dialect db.Dialect, schema TableSchema) (bool, error) {
+	keys := schema.MergeKeys
+	if sameColumns(keys, schema.PrimaryKey) {
+		return false, nil
+	}
+	name := truncateIdentifier(schema.Table+"_"+strings.Join(keys, "_"), dialect.MaxIdentifierLength()-len("_key")) + "_key"
+	created, err := db.EnsureUniqueIndex(conn, dialect, schema.Table, name, keys)
+	if err != nil {
+		return false, fmt.Errorf("creating unique index on %s (%s): %w", schema.Table, strings.Join(keys, ", "), err)
+	}
+	return created, nil
+}
+
+// prepareMerge readies loader to merge rows into the plan's table on its
+// merge keys, turning the native loader off since it cannot update rows
+func prepareMerge(dialect db.Dialect, schema TableSchema, loader *tableLoader, reporter Reporter) {
+	keys := schema.MergeKeys
+	columns := schema.ColumnNames()
+	for _, key := range keys {
+		for i, c := range columns {
+			if c == key {
+				loader.keyFields = append(loader.keyFields, i)
+			}
+		}
+	}
+	if loader.native != nil {
+		reporter.Log(fmt.Sprintf("%s: merging rows, using INSERT instead of the native bulk loader", schema.Table))
+		loader.native = nil
+	}
+	loader.upsert = dialect.UpsertClause(keys, columns)
+	loader.keyLength = keyLength(dialect)
+}
+
+// checkKeys refuses a row whose text merge key is longer than the key
+// column, which the engine might otherwise cut short so that it matches
+// another row's key
+func (l *tableLoader) checkKeys(values []interface{}) error {
+	if l.keyLength == 0 {
+		return nil
+	}
+	for _, f := range l.keyFields {
+		if s, ok := values[f].(string); ok && utf8.RuneCountInString(s) > l.keyLength {
+			return fmt.Errorf("merge key %s is longer than %d characters", l.headers[f], l.keyLength)
+		}
+	}
+	return nil
+}
+
+// collapseKeys keeps only the last of the rows of a batch that share merge
+// keys, which is what merging them one after another would leave. Some
+// engines refuse to update the same row twice in one statement. The rows
+// dropped count as loaded. Rows with a NULL key never match another row.
+func (l *tableLoader) collapseKeys(rows []loadRow) []loadRow {
+	last := make(map[string]int, len(rows))
+	keys := make([]string, len(rows))
+	for i, r := range rows {
+		parts := make([]string, len(l.keyFields))
+		for j, f := range l.keyFields {
+			if r.values[f] == nil {
+				parts = nil
+				break
+			}
+			parts[j] = fmt.Sprintf("%T:%v", r.values[f], r.values[f])
+		}
+		if parts != nil {
+			keys[i] = strings.Join(parts, "\x00")
+			last[keys[i]] = i
+		}
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+	kept := rows[:0:0]
+	for i, r := range rows {
+		if keys[i] == "" || last[keys[i]] == i {
+			kept = append(kept, r)
+		}
+	}
+	l.loaded.Add(int64(len(rows) - len(kept)))
+	return kept
+}
+
+// countRows returns the number of rows in table
+func countRows(conn execer, dialect db.Dialect, table string) (int64, error) {
+	var n int64
+	err := conn.QueryRow("SELECT COUNT(*) FROM " + dialect.QuoteIdent(table)).Scan(&n)
+	return n, err
+}
+
+// sameColumns reports whether a and b hold the same column names in any
+// order
+func sameColumns(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			found = found || x == y
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// FilePlan is what importing one CSV file will do. It is worked out without
+// touching the database, so it can be reviewed and edited first.
+type FilePlan struct {
+	FileName string
+	Path     string
+	// SchemaPath is the schema file that was applied, if any.
+	SchemaPath string
+	// Format is the CSV format with detected settings filled in.
+	Format CSVFormat
+	Schema TableSchema
+	// Renames are the headers that were changed to make column names.
+	Renames []HeaderRename
+	// IfExists is what happens when the table is already there.
+	IfExists ConflictStrategy
+	// Err is why the file cannot be imported; it is reported when the plan
+	// is executed.
+	Err error
+
+	inferred   []ColumnReport
+	fileSchema *FileSchema
+}
+
+// ColumnEdit changes one column of a plan. Empty fields keep what the
+// inference or schema file decided.
+type ColumnEdit struct {
+	Name string
+	// Type is a type name as accepted by ParseColumnType.
+	Type string
+	Skip bool
+	// Key makes the column a merge key. Unlike the other fields it is not
+	// laid over anything: the merge keys become exactly the Key columns.
+	Key bool
+}
+
+// PlanImport reads every CSV file in folderPath, infers its columns and
+// applies any schema file. dbType decides the column name limits. Only an
+// unreadable folder or unknown database type is an error; problems with
+// single files are kept in their plan.
+func PlanImport(folderPath string, dbType string, opts Options) ([]*FilePlan, error) {
+	dialect, err := db.DialectFor(dbType)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading folder: %w", err)
+	}
+
+	var plans []*FilePlan
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".csv") || isRejectedFile(entry.Name()) {
+			continue
+		}
+		plans = append(plans, planFile(folderPath, entry.Name(), dialect, opts))
+	}
+	return plans, nil
+}
+
+func planFile(folderPath string, fileName string, dialect db.Dialect, opts Options) *FilePlan {
+	plan := &FilePlan{FileName: fileName, Path: filepath.Join(folderPath, fileName), IfExists: opts.IfExists}
+	if plan.IfExists == "" {
+		plan.IfExists = ConflictAppend
+	}
+
+	// The schema file is read first since it can change how the CSV is read
+	var err error
+	plan.fileSchema, plan.SchemaPath, err = loadFileSchema(folderPath, fileName)
+	if err != nil {
+		plan.Err = fmt.Errorf("reading schema for %s: %w", fileName, err)
+		return plan
+	}
+	format := opts.CSV
+	var names []string
+	if fs := plan.fileSchema; fs != nil {
+		if fs.NoHeader != nil {
+			format.NoHeader = *fs.NoHeader
+		}
+		if fs.SkipLines != nil {
+			format.SkipLines = *fs.SkipLines
+		}
+		if len(fs.Headers) > 0 && !format.NoHeader {
+			plan.Err = fmt.Errorf("schema for %s: headers are only used when the file has no header row", fileName)
+			return plan
+		}
+		names = fs.Headers
+		if fs.IfExists != "" {
+			if plan.IfExists, err = ParseConflictStrategy(fs.IfExists); err != nil {
+				plan.Err = fmt.Errorf("schema for %s: %w", fileName, err)
+				return plan
+			}
+		}
+	}
+	if err := format.Validate(); err != nil {
+		plan.Err = fmt.Errorf("schema for %s: %w", fileName, err)
+		return plan
+	}
+
+	format, err = sniffFormat(plan.Path, format)
+	if err != nil {
+		plan.Err = fmt.Errorf("reading CSV %s: %w", fileName, err)
+		return plan
+	}
+	plan.Format = format
+
+	// Schema columns are keyed by header or by column name, so the names
+	// are made as soon as the header is read, before any null is counted
+	var columnNames []string
+	var renames []HeaderRename
+	columnNulls := func(headers []string) [][]string {
+		columnNames, renames = normalizeHeaders(headers, dialect, opts.Headers)
+		nulls := make([][]string, len(headers))
+		if plan.fileSchema != nil {
+			for key, c := range plan.fileSchema.Columns {
+				if c.NullTokens == nil {
+					continue
+				}
+				for _, i := range schemaColumnFields(key, headers, columnNames) {
+					nulls[i] = c.NullTokens
+				}
+			}
+		}
+		return nulls
+	}
+	headers, columns, err := inferCSVFile(plan.Path, format, names, columnNulls, opts)
+	if err != nil {
+		plan.Err = fmt.Errorf("reading CSV %s: %w", fileName, err)
+		return plan
+	}
+	for i := range columns {
+		columns[i].Name = columnNames[i]
+	}
+	plan.inferred, plan.Renames = columns, renames
+	plan.Schema, err = newTableSchema(plan.defaultTable(), headers, columns, plan.fileSchema, opts.MergeKeys)
+	if err != nil {
+		plan.Err = fmt.Errorf("schema for %s: %w", fileName, err)
+	}
+	return plan
+}
+
+// defaultTable is the table name used when nothing overrides it
+func (p *FilePlan) defaultTable() string {
+	return strings.TrimSuffix(p.FileName, ".csv")
+}
+
+// Edit re-derives the plan's schema with the given table name and column
+// edits, keyed by position in the CSV header, laid over the schema file.
+// Each call starts again from the schema file, so it must carry every edit
+// wanted.
+func (p *FilePlan) Edit(table string, edits map[int]ColumnEdit) error {
+	if p.inferred == nil {
+		return p.Err
+	}
+	overrides, err := columnOverrides(p.fileSchema, p.Schema.Headers, p.inferred)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p.FileName, err)
+	}
+	var primaryKey []string
+	if p.fileSchema != nil {
+		if table == "" {
+			table = p.fileSchema.Table
+		}
+		primaryKey = p.fileSchema.PrimaryKey
+	}
+	if table == "" {
+		table = p.defaultTable()
+	}
+	for field, e := range edits {
+		if field < 0 || field >= len(overrides) {
+			return fmt.Errorf("%s: no CSV column %d", p.FileName, field+1)
+		}
+		c := &overrides[field]
+		if e.Name != "" {
+			c.Name = e.Name
+		}
+		if e.Type != "" {
+			c.Type = e.Type
+		}
+		if e.Skip {
+			c.Drop = true
+		}
+	}
+
+	schema, err := buildTableSchema(table, p.Schema.Headers, p.inferred, overrides, primaryKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p.FileName, err)
+	}
+	for i, field := range schema.Fields {
+		if edits[field].Key {
+			schema.MergeKeys = append(schema.MergeKeys, schema.Columns[i].Name)
+		}
+	}
+	p.Schema = schema
+	return nil
+}
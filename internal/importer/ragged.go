@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RaggedPolicy says what happens to a row whose field count differs from
+// the header
+type RaggedPolicy string
+
+const (
+	// RaggedPad loads a short row with NULL for the missing fields.
+	RaggedPad RaggedPolicy = "pad"
+	// RaggedTruncate loads a long row without its extra fields.
+	RaggedTruncate RaggedPolicy = "truncate"
+	// RaggedReject skips the row and writes it to the file's rejected rows.
+	RaggedReject RaggedPolicy = "reject"
+	// RaggedAbort stops the file at the row.
+	RaggedAbort RaggedPolicy = "abort"
+)
+
+// ParseRaggedPolicy validates a policy for rows with too many fields when
+// long is set, or too few otherwise. pad only applies to short rows and
+// truncate to long ones.
+func ParseRaggedPolicy(s string, long bool) (RaggedPolicy, error) {
+	switch p := RaggedPolicy(strings.ToLower(s)); p {
+	case "":
+		return RaggedAbort, nil
+	case RaggedReject, RaggedAbort:
+		return p, nil
+	case RaggedPad:
+		if !long {
+			return p, nil
+		}
+	case RaggedTruncate:
+		if long {
+			return p, nil
+		}
+	}
+	if long {
+		return "", fmt.Errorf("unknown policy %q for long rows (want truncate, reject or abort)", s)
+	}
+	return "", fmt.Errorf("unknown policy %q for short rows (want pad, reject or abort)", s)
+}
+
+// raggedRows applies the ragged row policies of an import to one file and
+// counts what they did. It is used by the reading goroutine only.
+type raggedRows struct {
+	width     int
+	short     RaggedPolicy
+	long      RaggedPolicy
+	padded    int
+	truncated int
+	rejected  int
+}
+
+func newRaggedRows(width int, opts Options) *raggedRows {
+	return &raggedRows{width: width, short: opts.ShortRows, long: opts.LongRows}
+}
+
+// fit returns record ready to convert. Padded rows stay short, since the
+// converter reads missing fields as NULL. When the row cannot be loaded err
+// says why, and rejected tells whether it is only skipped or the file must
+// stop.
+func (p *raggedRows) fit(record []string) (fitted []string, rejected bool, err error) {
+	if len(record) == p.width {
+		return record, false, nil
+	}
+	policy := p.short
+	if len(record) > p.width {
+		policy = p.long
+	}
+	switch policy {
+	case RaggedPad:
+		p.padded++
+		return record, false, nil
+	case RaggedTruncate:
+		p.truncated++
+		return record[:p.width], false, nil
+	}
+	err = fmt.Errorf("row has %d fields, the header has %d", len(record), p.width)
+	if policy == RaggedReject {
+		p.rejected++
+		return nil, true, err
+	}
+	return nil, false, err
+}
+
+// String summarises what was done, or is empty when every row fitted
+func (p *raggedRows) String() string {
+	var parts []string
+	if p.padded > 0 {
+		parts = append(parts, fmt.Sprintf("%d short rows padded with NULL", p.padded))
+	}
+	if p.truncated > 0 {
+		parts = append(parts, fmt.Sprintf("%d long rows truncated", p.truncated))
+	}
+	if p.rejected > 0 {
+		parts = append(parts, fmt.Sprintf("%d ragged rows rejected", p.rejected))
+	}
+	return strings.Join(parts, ", ")
+}
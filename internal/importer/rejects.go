@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Rows that are skipped are written next to their CSV file as
+// orders.csv.rejected.csv, which later imports of the folder leave alone.
+const rejectedSuffix = ".rejected.csv"
+
+func isRejectedFile(name string) bool {
+	return strings.HasSuffix(name, rejectedSuffix)
+}
+
+// rejectWriter collects the rows of one file that were not loaded, with
+// their line number and the reason. The file is only created once a row is
+// rejected, and is safe to use from several workers.
+type rejectWriter struct {
+	path    string
+	headers []string
+
+	mu    sync.Mutex
+	file  *os.File
+	w     *csv.Writer
+	count int
+}
+
+// newRejectWriter removes the rejected rows left by an earlier import of
+// csvPath, so the file only ever describes the latest one
+func newRejectWriter(csvPath string, headers []string) (*rejectWriter, error) {
+	path := csvPath + rejectedSuffix
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return &rejectWriter{path: path, headers: headers}, nil
+}
+
+func (w *rejectWriter) write(line int, reason string, record []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		f, err := os.Create(w.path)
+		if err != nil {
+			return err
+		}
+		w.file, w.w = f, csv.NewWriter(f)
+		if err := w.w.Write(append([]string{"line", "error"}, w.headers...)); err != nil {
+			return err
+		}
+	}
+	w.count++
+	return w.w.Write(append([]string{strconv.Itoa(line), reason}, record...))
+}
+
+// Count is the number of rows written so far
+func (w *rejectWriter) Count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+func (w *rejectWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	w.w.Flush()
+	err := w.w.Error()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
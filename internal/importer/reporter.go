@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Reporter observes an import as it runs. Implementations must be safe for
+// concurrent use, since insert workers report from their own goroutines.
+type Reporter interface {
+	// Log records an informational line.
+	Log(message string)
+	// PhaseStart and PhaseEnd bracket a long-running step such as connecting.
+	PhaseStart(phase string)
+	PhaseEnd(phase string, err error)
+	// FileStart and FileEnd bracket each CSV file; index is zero based.
+	FileStart(name string, index int, total int)
+	FileEnd(name string, err error)
+	// TableProgress reports how much of a table's data has been loaded.
+	TableProgress(table string, percent int)
+	// Error records a failure that did not stop the whole import.
+	Error(err error)
+}
+
+// TextReporter writes timestamped plain-text lines, for terminals and log files
+type TextReporter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	lastPercent map[string]int
+}
+
+func NewTextReporter(w io.Writer) *TextReporter {
+	return &TextReporter{w: w, lastPercent: make(map[string]int)}
+}
+
+func (r *TextReporter) println(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "[%s] %s\n", time.Now().Format("15:04:05"), message)
+}
+
+func (r *TextReporter) Log(message string) {
+	r.println(message)
+}
+
+func (r *TextReporter) PhaseStart(phase string) {
+	r.println(phase + "...")
+}
+
+func (r *TextReporter) PhaseEnd(phase string, err error) {
+	if err != nil {
+		r.println(fmt.Sprintf("%s - Failed: %v", phase, err))
+		return
+	}
+	r.println(phase + " - Complete")
+}
+
+func (r *TextReporter) FileStart(name string, index int, total int) {
+	r.println(fmt.Sprintf("Processing file %d/%d: %s", index+1, total, name))
+}
+
+func (r *TextReporter) FileEnd(name string, err error) {
+	if err != nil {
+		r.println(fmt.Sprintf("Failed: %s: %v", name, err))
+		return
+	}
+	r.println(fmt.Sprintf("Finished: %s", name))
+}
+
+func (r *TextReporter) TableProgress(table string, percent int) {
+	r.mu.Lock()
+	last, seen := r.lastPercent[table]
+	if seen && last == percent {
+		r.mu.Unlock()
+		return
+	}
+	r.lastPercent[table] = percent
+	r.mu.Unlock()
+	r.println(fmt.Sprintf("%s: %d%%", table, percent))
+}
+
+func (r *TextReporter) Error(err error) {
+	r.println(fmt.Sprintf("Error: %v", err))
+}
+
+// NopReporter discards everything
+type NopReporter struct{}
+
+func (NopReporter) Log(string)                 {}
+func (NopReporter) PhaseStart(string)          {}
+func (NopReporter) PhaseEnd(string, error)     {}
+func (NopReporter) FileStart(string, int, int) {}
+func (NopReporter) FileEnd(string, error)      {}
+func (NopReporter) TableProgress(string, int)  {}
+func (NopReporter) Error(error)                {}
+
+// EventKind identifies which Reporter method produced an Event
+type EventKind string
+
+const (
+	EventLog           EventKind = "log"
+	EventPhaseStart    EventKind = "phase_start"
+	EventPhaseEnd      EventKind = "phase_end"
+	EventFileStart     EventKind = "file_start"
+	EventFileEnd       EventKind = "file_end"
+	EventTableProgress EventKind = "table_progress"
+	EventError         EventKind = "error"
+)
+
+// Event is a single call recorded by Recorder
+type Event struct {
+	Kind    EventKind
+	Name    string // phase, file or table name
+	Message string
+	Index   int
+	Total   int
+	Percent int
+	Err     error
+}
+
+// Recorder keeps every reported event in memory, for tests and for callers
+// that want to inspect an import after it has finished.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *Recorder) record(e Event) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.mu.Unlock()
+}
+
+// Events returns a copy of everything recorded so far
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Errors returns the errors recorded through Error and failed FileEnd calls
+func (r *Recorder) Errors() []error {
+	var errs []error
+	for _, e := range r.Events() {
+		if e.Err != nil && (e.Kind == EventError || e.Kind == EventFileEnd) {
+			errs = append(errs, e.Err)
+		}
+	}
+	return errs
+}
+
+func (r *Recorder) Log(message string) {
+	r.record(Event{Kind: EventLog, Message: message})
+}
+
+func (r *Recorder) PhaseStart(phase string) {
+	r.record(Event{Kind: EventPhaseStart, Name: phase})
+}
+
+func (r *Recorder) PhaseEnd(phase string, err error) {
+	r.record(Event{Kind: EventPhaseEnd, Name: phase, Err: err})
+}
+
+func (r *Recorder) FileStart(name string, index int, total int) {
+	r.record(Event{Kind: EventFileStart, Name: name, Index: index, Total: total})
+}
+
+func (r *Recorder) FileEnd(name string, err error) {
+	r.record(Event{Kind: EventFileEnd, Name: name, Err: err})
+}
+
+func (r *Recorder) TableProgress(table string, percent int) {
+	r.record(Event{Kind: EventTableProgress, Name: table, Percent: percent})
+}
+
+func (r *Recorder) Error(err error) {
+	r.record(Event{Kind: EventError, Err: err})
+}
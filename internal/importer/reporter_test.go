@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestTextReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewTextReporter(&out)
+	failed := errors.New("refused")
+
+	r.PhaseStart("Connecting")
+	r.PhaseEnd("Connecting", nil)
+	r.PhaseStart("Planning")
+	r.PhaseEnd("Planning", failed)
+	r.FileStart("a.csv", 0, 2)
+	r.TableProgress("a", 50)
+	r.TableProgress("a", 50)
+	r.TableProgress("a", 100)
+	r.FileEnd("a.csv", nil)
+	r.FileEnd("b.csv", failed)
+	r.Log("done")
+	r.Error(failed)
+
+	want := []string{
+		"Connecting...",
+		"Connecting - Complete",
+		"Planning...",
+		"Planning - Failed: refused",
+		"Processing file 1/2: a.csv",
+		"a: 50%",
+		"a: 100%",
+		"Finished: a.csv",
+		"Failed: b.csv: refused",
+		"done",
+		"Error: refused",
+	}
+	line := regexp.MustCompile(`(?m)^\[\d\d:\d\d:\d\d\] (.*)$`)
+	var got []string
+	for _, m := range line.FindAllStringSubmatch(out.String(), -1) {
+		got = append(got, m[1])
+	}
+	if !reflect.DeepEqual(got, want) || bytes.Count(out.Bytes(), []byte("\n")) != len(want) {
+		t.Errorf("output:\n%s\nwant lines %q", out.String(), want)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := &Recorder{}
+	failed := errors.New("refused")
+
+	r.PhaseStart("Connecting")
+	r.PhaseEnd("Connecting", failed)
+	r.FileStart("a.csv", 1, 3)
+	r.TableProgress("a", 40)
+	r.FileEnd("a.csv", failed)
+	r.FileEnd("b.csv", nil)
+	r.Log("done")
+	r.Error(failed)
+
+	want := []Event{
+		{Kind: EventPhaseStart, Name: "Connecting"},
+		{Kind: EventPhaseEnd, Name: "Connecting", Err: failed},
+		{Kind: EventFileStart, Name: "a.csv", Index: 1, Total: 3},
+		{Kind: EventTableProgress, Name: "a", Percent: 40},
+		{Kind: EventFileEnd, Name: "a.csv", Err: failed},
+		{Kind: EventFileEnd, Name: "b.csv"},
+		{Kind: EventLog, Message: "done"},
+		{Kind: EventError, Err: failed},
+	}
+	if got := r.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+	// a failed phase is not an import error
+	if got := r.Errors(); len(got) != 2 || got[0] != failed || got[1] != failed {
+		t.Errorf("errors = %v, want the failed file and the reported error", got)
+	}
+
+	events := r.Events()
+	events[0].Name = "changed"
+	if r.Events()[0].Name != "Connecting" {
+		t.Error("Events shares its slice with the recorder")
+	}
+}
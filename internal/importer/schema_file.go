@@ -0,0 +1,336 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+// A CSV file can carry a sidecar schema, orders.csv.schema.yaml (or .yml,
+// .json). Files without one are looked up in the folder's mapping file,
+// which lists schemas under "files" by CSV file name.
+var (
+	schemaSuffixes   = []string{".schema.yaml", ".schema.yml", ".schema.json"}
+	mappingFileNames = []string{"mapping.yaml", "mapping.yml", "mapping.json"}
+)
+
+// FileSchema overrides what the importer derives from a CSV file. Columns
+// are keyed by their CSV header, or by the column name made from it when
+// headers repeat; columns it does not mention keep that name and their
+// inferred type.
+type FileSchema struct {
+	Table      string                  `json:"table" yaml:"table"`
+	Columns    map[string]ColumnSchema `json:"columns" yaml:"columns"`
+	PrimaryKey []string                `json:"primary_key" yaml:"primary_key"`
+	// MergeKeys replace the import's merge keys for this file; [] turns
+	// merging off.
+	MergeKeys []string `json:"merge_keys" yaml:"merge_keys"`
+	// NoHeader and SkipLines override the import settings for this file.
+	// Headers names the columns of a headerless file in order; Columns is
+	// then keyed by these names, or by col_1 to col_n.
+	NoHeader  *bool    `json:"no_header" yaml:"no_header"`
+	SkipLines *int     `json:"skip_lines" yaml:"skip_lines"`
+	Headers   []string `json:"headers" yaml:"headers"`
+	// IfExists is the ConflictStrategy for this file's table.
+	IfExists string `json:"if_exists" yaml:"if_exists"`
+}
+
+// ColumnSchema overrides one column. Type takes the inferred type names
+// (bool, int, bigint, decimal(12,2), float, date, time, timestamp,
+// timestamptz, uuid, json, text) and the usual SQL aliases. Default is the
+// value loaded for null cells, written as it would appear in the CSV.
+// NullTokens replace the import's null tokens for the column; [] keeps
+// empty cells as empty strings.
+type ColumnSchema struct {
+	Name       string   `json:"name" yaml:"name"`
+	Type       string   `json:"type" yaml:"type"`
+	Layout     string   `json:"layout" yaml:"layout"`
+	Nullable   *bool    `json:"nullable" yaml:"nullable"`
+	Default    *string  `json:"default" yaml:"default"`
+	NullTokens []string `json:"null_tokens" yaml:"null_tokens"`
+	Drop       bool     `json:"drop" yaml:"drop"`
+}
+
+type mappingFile struct {
+	Files map[string]FileSchema `json:"files" yaml:"files"`
+}
+
+// TableSchema is the table a CSV file is loaded into: the inferred columns
+// with any schema file applied, in CSV order.
+type TableSchema struct {
+	Table string
+	// Headers is the CSV header row, Fields the index in it that each of
+	// Columns is read from.
+	Headers    []string
+	Columns    []ColumnReport
+	Fields     []int
+	PrimaryKey []string
+	// MergeKeys are the columns that identify a row when merging: rows
+	// whose keys are already in the table update it instead of being added.
+	MergeKeys []string
+}
+
+// checkMergeKeys makes sure every merge key is a column of the table
+func (s TableSchema) checkMergeKeys() error {
+	for _, key := range s.MergeKeys {
+		found := false
+		for _, col := range s.Columns {
+			found = found || col.Name == key
+		}
+		if !found {
+			return fmt.Errorf("merge key column %q is not in the table", key)
+		}
+	}
+	return nil
+}
+
+// ColumnNames returns the target column names
+func (s TableSchema) ColumnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+var sizedTypePattern = regexp.MustCompile(`^(\w+)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)$`)
+
+// ParseColumnType reads a type name from a schema file. precision and scale
+// are only set for a sized decimal such as numeric(12,2).
+func ParseColumnType(s string) (t db.ColumnType, precision int, scale int, err error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if m := sizedTypePattern.FindStringSubmatch(name); m != nil {
+		name = m[1]
+		precision, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			scale, _ = strconv.Atoi(m[3])
+		}
+		if name != "decimal" && name != "numeric" {
+			return "", 0, 0, fmt.Errorf("type %q does not take a size", s)
+		}
+		if precision < 1 || scale > precision {
+			return "", 0, 0, fmt.Errorf("invalid precision or scale in %q", s)
+		}
+	}
+	switch name {
+	case "bool", "boolean":
+		return db.TypeBool, 0, 0, nil
+	case "int", "integer":
+		return db.TypeInt, 0, 0, nil
+	case "bigint":
+		return db.TypeBigInt, 0, 0, nil
+	case "decimal", "numeric":
+		return db.TypeDecimal, precision, scale, nil
+	case "float", "double", "real":
+		return db.TypeFloat, 0, 0, nil
+	case "date":
+		return db.TypeDate, 0, 0, nil
+	case "time":
+		return db.TypeTime, 0, 0, nil
+	case "timestamp", "datetime":
+		return db.TypeTimestamp, 0, 0, nil
+	case "timestamptz":
+		return db.TypeTimestampTZ, 0, 0, nil
+	case "uuid":
+		return db.TypeUUID, 0, 0, nil
+	case "json", "jsonb":
+		return db.TypeJSON, 0, 0, nil
+	case "text", "string":
+		return db.TypeText, 0, 0, nil
+	}
+	return "", 0, 0, fmt.Errorf("unknown column type %q", s)
+}
+
+// decodeSchema reads YAML or JSON by extension, rejecting unknown keys so a
+// misspelt setting isn't silently ignored
+func decodeSchema(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(v)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// loadFileSchema finds the schema for fileName in folderPath. It returns nil
+// and no error when there is none, and the path of the file it used.
+func loadFileSchema(folderPath string, fileName string) (*FileSchema, string, error) {
+	for _, suffix := range schemaSuffixes {
+		path := filepath.Join(folderPath, fileName+suffix)
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, "", err
+		}
+		var schema FileSchema
+		if err := decodeSchema(path, &schema); err != nil {
+			return nil, "", err
+		}
+		return &schema, path, nil
+	}
+
+	for _, name := range mappingFileNames {
+		path := filepath.Join(folderPath, name)
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, "", err
+		}
+		var mapping mappingFile
+		if err := decodeSchema(path, &mapping); err != nil {
+			return nil, "", err
+		}
+		if schema, ok := mapping.Files[fileName]; ok {
+			return &schema, path, nil
+		}
+		return nil, "", nil
+	}
+	return nil, "", nil
+}
+
+// columnOverrides returns the override of every column of a file, found by
+// CSV header or by inferred column name. fs may be nil.
+func columnOverrides(fs *FileSchema, headers []string, columns []ColumnReport) ([]ColumnSchema, error) {
+	overrides := make([]ColumnSchema, len(columns))
+	if fs == nil {
+		return overrides, nil
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	for key, override := range fs.Columns {
+		fields := schemaColumnFields(key, headers, names)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("schema column %q is not in the CSV header", key)
+		}
+		for _, i := range fields {
+			overrides[i] = override
+		}
+	}
+	return overrides, nil
+}
+
+// schemaColumnFields returns the positions a schema column key applies to:
+// those with that CSV header, or else those with that column name
+func schemaColumnFields(key string, headers []string, names []string) []int {
+	var fields []int
+	for i, h := range headers {
+		if h == key {
+			fields = append(fields, i)
+		}
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+	for i, n := range names {
+		if n == key {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// newTableSchema applies fs, which may be nil, to the inferred columns of a
+// file. table and mergeKeys are used when fs doesn't set them.
+func newTableSchema(table string, headers []string, columns []ColumnReport, fs *FileSchema, mergeKeys []string) (TableSchema, error) {
+	overrides, err := columnOverrides(fs, headers, columns)
+	if err != nil {
+		return TableSchema{Table: table, Headers: headers}, err
+	}
+	var primaryKey []string
+	if fs != nil {
+		if fs.Table != "" {
+			table = fs.Table
+		}
+		primaryKey = fs.PrimaryKey
+		if fs.MergeKeys != nil {
+			mergeKeys = fs.MergeKeys
+		}
+	}
+	schema, err := buildTableSchema(table, headers, columns, overrides, primaryKey)
+	if err != nil {
+		return schema, err
+	}
+	schema.MergeKeys = mergeKeys
+	return schema, schema.checkMergeKeys()
+}
+
+// buildTableSchema applies one override to each inferred column
+func buildTableSchema(table string, headers []string, columns []ColumnReport, overrides []ColumnSchema, primaryKey []string) (TableSchema, error) {
+	schema := TableSchema{Table: table, Headers: headers}
+	seen := make(map[string]bool, len(columns))
+	for i, col := range columns {
+		override := overrides[i]
+		if override.Drop {
+			continue
+		}
+		if override.Name != "" {
+			col.Name = override.Name
+		}
+		if override.Type != "" {
+			t, precision, scale, err := ParseColumnType(override.Type)
+			if err != nil {
+				return schema, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			if t != col.Type {
+				col.Counts, col.WidenedBy, col.Ambiguous = nil, "", nil
+				if !isTemporal(t) {
+					col.Layout = ""
+				}
+			}
+			col.Type, col.Precision, col.Scale = t, precision, scale
+		}
+		if override.Layout != "" {
+			col.Layout, col.Ambiguous = override.Layout, nil
+		}
+		if override.Nullable != nil {
+			col.NotNull = !*override.Nullable
+		}
+		if override.Default != nil {
+			col.Default = override.Default
+		}
+		if override.NullTokens != nil {
+			col.NullTokens = override.NullTokens
+		}
+		if seen[col.Name] {
+			return schema, fmt.Errorf("column %q appears twice", col.Name)
+		}
+		seen[col.Name] = true
+		schema.Columns = append(schema.Columns, col)
+		schema.Fields = append(schema.Fields, i)
+	}
+	if len(schema.Columns) == 0 {
+		return schema, fmt.Errorf("schema drops every column")
+	}
+
+	for _, key := range primaryKey {
+		if !seen[key] {
+			return schema, fmt.Errorf("primary key column %q is not in the table", key)
+		}
+	}
+	schema.PrimaryKey = primaryKey
+	return schema, nil
+}
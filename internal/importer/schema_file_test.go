@@ -0,0 +1,214 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+func TestLoadFileSchema(t *testing.T) {
+	no, two := false, 2
+	zero := "0"
+	cases := []struct {
+		name    string
+		files   map[string]string
+		want    *FileSchema
+		wantErr string
+	}{
+		{
+			name:  "no schema",
+			files: map[string]string{"other.csv.schema.yaml": "table: other\n"},
+		},
+		{
+			name: "YAML sidecar",
+			files: map[string]string{"t.csv.schema.yaml": `table: orders
+primary_key: [id]
+skip_lines: 2
+columns:
+  Amount: {name: amount, type: "decimal(8,2)", nullable: false, default: "0"}
+  note: {drop: true}
+`},
+			want: &FileSchema{
+				Table:      "orders",
+				PrimaryKey: []string{"id"},
+				SkipLines:  &two,
+				Columns: map[string]ColumnSchema{
+					"Amount": {Name: "amount", Type: "decimal(8,2)", Nullable: &no, Default: &zero},
+					"note":   {Drop: true},
+				},
+			},
+		},
+		{
+			name:  "yml sidecar",
+			files: map[string]string{"t.csv.schema.yml": "if_exists: replace\n"},
+			want:  &FileSchema{IfExists: "replace"},
+		},
+		{
+			name: "JSON sidecar",
+			files: map[string]string{"t.csv.schema.json": `{"no_header": false, "headers": ["a", "b"],
+				"columns": {"a": {"null_tokens": ["-"]}}, "merge_keys": []}`},
+			want: &FileSchema{
+				NoHeader:  &no,
+				Headers:   []string{"a", "b"},
+				Columns:   map[string]ColumnSchema{"a": {NullTokens: []string{"-"}}},
+				MergeKeys: []string{},
+			},
+		},
+		{
+			name: "sidecar wins over the mapping file",
+			files: map[string]string{
+				"t.csv.schema.yaml": "table: from_sidecar\n",
+				"mapping.yaml":      "files:\n  t.csv: {table: from_mapping}\n",
+			},
+			want: &FileSchema{Table: "from_sidecar"},
+		},
+		{
+			name:  "mapping file",
+			files: map[string]string{"mapping.json": `{"files": {"t.csv": {"table": "from_mapping"}}}`},
+			want:  &FileSchema{Table: "from_mapping"},
+		},
+		{
+			name:  "mapping file without the file",
+			files: map[string]string{"mapping.yaml": "files:\n  other.csv: {table: other}\n"},
+		},
+		{
+			name:    "unknown YAML key",
+			files:   map[string]string{"t.csv.schema.yaml": "primary_keys: [id]\n"},
+			wantErr: "field primary_keys not found",
+		},
+		{
+			name:    "unknown JSON key",
+			files:   map[string]string{"t.csv.schema.json": `{"columns": {"a": {"nulable": true}}}`},
+			wantErr: `unknown field "nulable"`,
+		},
+		{
+			name:    "unknown key in the mapping file",
+			files:   map[string]string{"mapping.yaml": "file:\n  t.csv: {table: x}\n"},
+			wantErr: "field file not found",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range c.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, _, err := loadFileSchema(dir, "t.csv")
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewTableSchema(t *testing.T) {
+	yes, no := true, false
+	zero := "0"
+	headers := []string{"id", "Full Name", "amount"}
+	inferred := func() []ColumnReport {
+		return []ColumnReport{
+			{Name: "id", Type: db.TypeInt},
+			{Name: "full_name", Type: db.TypeText, Nullable: true},
+			{Name: "amount", Type: db.TypeDate, Layout: "2006-01-02", Nullable: true},
+		}
+	}
+	cases := []struct {
+		name        string
+		fs          *FileSchema
+		wantTable   string
+		wantColumns []ColumnReport
+		wantFields  []int
+		wantErr     string
+	}{
+		{
+			name:        "no schema",
+			wantTable:   "t",
+			wantColumns: inferred(),
+			wantFields:  []int{0, 1, 2},
+		},
+		{
+			name: "renamed by header and retyped by name",
+			fs: &FileSchema{
+				Table: "people",
+				Columns: map[string]ColumnSchema{
+					"Full Name": {Name: "name"},
+					"amount":    {Type: "numeric(8,2)", Nullable: &no, Default: &zero},
+					"id":        {Nullable: &yes},
+				},
+			},
+			wantTable: "people",
+			wantColumns: []ColumnReport{
+				{Name: "id", Type: db.TypeInt},
+				{Name: "name", Type: db.TypeText, Nullable: true},
+				{Name: "amount", Type: db.TypeDecimal, Precision: 8, Scale: 2, Nullable: true, NotNull: true, Default: &zero},
+			},
+			wantFields: []int{0, 1, 2},
+		},
+		{
+			name:        "dropped column",
+			fs:          &FileSchema{Columns: map[string]ColumnSchema{"full_name": {Drop: true}}},
+			wantTable:   "t",
+			wantColumns: []ColumnReport{inferred()[0], inferred()[2]},
+			wantFields:  []int{0, 2},
+		},
+		{
+			name:    "unknown column",
+			fs:      &FileSchema{Columns: map[string]ColumnSchema{"nope": {Type: "int"}}},
+			wantErr: `schema column "nope" is not in the CSV header`,
+		},
+		{
+			name:    "unknown type",
+			fs:      &FileSchema{Columns: map[string]ColumnSchema{"id": {Type: "money"}}},
+			wantErr: `unknown column type "money"`,
+		},
+		{
+			name:    "rename onto another column",
+			fs:      &FileSchema{Columns: map[string]ColumnSchema{"id": {Name: "amount"}}},
+			wantErr: `column "amount" appears twice`,
+		},
+		{
+			name: "every column dropped",
+			fs: &FileSchema{Columns: map[string]ColumnSchema{
+				"id": {Drop: true}, "full_name": {Drop: true}, "amount": {Drop: true},
+			}},
+			wantErr: "schema drops every column",
+		},
+		{
+			name:    "primary key not in the table",
+			fs:      &FileSchema{PrimaryKey: []string{"Full Name"}},
+			wantErr: `primary key column "Full Name" is not in the table`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			schema, err := newTableSchema("t", headers, inferred(), c.fs, nil)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema.Table != c.wantTable || !reflect.DeepEqual(schema.Columns, c.wantColumns) || !reflect.DeepEqual(schema.Fields, c.wantFields) {
+				t.Errorf("got %s %+v %v, want %s %+v %v", schema.Table, schema.Columns, schema.Fields, c.wantTable, c.wantColumns, c.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devakdogan/go_csv_adapter/internal/db"
+)
+
+// Pseudo-layouts for integer Unix timestamps. They are only tried on columns
+// whose name looks like a time, such as created_at or event_time.
+const (
+	LayoutUnix      = "unix"
+	LayoutUnixMilli = "unixms"
+)
+
+// Unix timestamps are only believed between 2000 and 2100
+const (
+	minUnix = 946684800
+	maxUnix = 4102444800
+)
+
+// DefaultTemporalLayouts are the time.Parse layouts tried on every column.
+// When values fit several layouts the first one listed wins, except that
+// day-first and month-first candidates are decided by Options.MonthFirst.
+var DefaultTemporalLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2/1/2006",
+	"1/2/2006",
+	"2.1.2006",
+	"2/1/2006 15:04:05",
+	"1/2/2006 15:04:05",
+	"2.1.2006 15:04:05",
+	"2/1/2006 15:04",
+	"1/2/2006 15:04",
+	"2.1.2006 15:04",
+	"15:04:05",
+	"15:04",
+	LayoutUnix,
+	LayoutUnixMilli,
+}
+
+// layoutKind returns the column type a layout produces, found by checking
+// which parts of a time change its formatted output
+func layoutKind(layout string) db.ColumnType {
+	if isUnixLayout(layout) {
+		return db.TypeTimestampTZ
+	}
+	base := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	s := base.Format(layout)
+	hasDate := s != base.AddDate(1, 1, 1).Format(layout)
+	hasClock := s != base.Add(time.Hour+time.Minute+time.Second).Format(layout)
+	hasZone := s != time.Date(2001, 2, 3, 4, 5, 6, 0, time.FixedZone("", 3*3600+1800)).Format(layout)
+	switch {
+	case hasDate && hasClock && hasZone:
+		return db.TypeTimestampTZ
+	case hasDate && hasClock:
+		return db.TypeTimestamp
+	case hasDate:
+		return db.TypeDate
+	case hasClock:
+		return db.TypeTime
+	}
+	return ""
+}
+
+// dayOrder reports whether a numeric layout puts the day before the month.
+// ok is false when the layout does not have both.
+func dayOrder(layout string) (dayFirst bool, ok bool) {
+	if isUnixLayout(layout) {
+		return false, false
+	}
+	s := time.Date(2001, 11, 22, 0, 0, 0, 0, time.UTC).Format(layout)
+	day, month := strings.Index(s, "22"), strings.Index(s, "11")
+	if day < 0 || month < 0 {
+		return false, false
+	}
+	return day < month, true
+}
+
+func isTemporal(t db.ColumnType) bool {
+	switch t {
+	case db.TypeDate, db.TypeTime, db.TypeTimestamp, db.TypeTimestampTZ:
+		return true
+	}
+	return false
+}
+
+func isUnixLayout(layout string) bool {
+	return layout == LayoutUnix || layout == LayoutUnixMilli
+}
+
+// hintsTime reports whether a column name suggests it holds timestamps
+func hintsTime(name string) bool {
+	n := strings.ToLower(name)
+	if n == "time" || n == "date" {
+		return true
+	}
+	for _, suffix := range []string{"_at", "_ts", "_time", "_date", "timestamp", "epoch"} {
+		if strings.HasSuffix(n, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTemporal parses v with layout, including the Unix pseudo-layouts
+func parseTemporal(layout string, v string) (time.Time, bool) {
+	if isUnixLayout(layout) {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if layout == LayoutUnixMilli {
+			if n < minUnix*1000 || n > maxUnix*1000 {
+				return time.Time{}, false
+			}
+			return time.UnixMilli(n).UTC(), true
+		}
+		if n < minUnix || n > maxUnix {
+			return time.Time{}, false
+		}
+		return time.Unix(n, 0).UTC(), true
+	}
+	t, err := time.Parse(layout, strings.TrimSpace(v))
+	return t, err == nil
+}
+
+// formatTemporal writes t in the ISO form every supported engine accepts.
+// Instants are converted to UTC so the offset is always +00:00.
+func formatTemporal(t db.ColumnType, value time.Time) string {
+	switch t {
+	case db.TypeDate:
+		return value.Format("2006-01-02")
+	case db.TypeTime:
+		return value.Format("15:04:05.999999")
+	case db.TypeTimestamp:
+		return value.Format("2006-01-02 15:04:05.999999")
+	case db.TypeTimestampTZ:
+		return value.UTC().Format("2006-01-02 15:04:05.999999-07:00")
+	}
+	return value.String()
+}
+
+// temporalCandidates tracks, for one column, the layouts that have parsed
+// every value seen so far
+type temporalCandidates struct {
+	layouts []string
+	// brokenBy is the value that ruled out the last layouts after earlier
+	// values had parsed
+	brokenBy string
+	matched  bool
+}
+
+func newTemporalCandidates(name string, layouts []string) *temporalCandidates {
+	c := &temporalCandidates{}
+	allowUnix := hintsTime(name)
+	for _, l := range layouts {
+		if isUnixLayout(l) && !allowUnix {
+			continue
+		}
+		if layoutKind(l) == "" {
+			continue
+		}
+		c.layouts = append(c.layouts, l)
+	}
+	return c
+}
+
+// observe drops the layouts that cannot parse v and returns the kind of the
+// first layout that can, or "" if none can
+func (c *temporalCandidates) observe(v string) db.ColumnType {
+	if len(c.layouts) == 0 {
+		return ""
+	}
+	kept := c.layouts[:0]
+	for _, l := range c.layouts {
+		if _, ok := parseTemporal(l, v); ok {
+			kept = append(kept, l)
+		}
+	}
+	c.layouts = kept
+	if len(kept) == 0 {
+		if c.matched {
+			c.brokenBy = v
+		}
+		return ""
+	}
+	c.matched = true
+	return layoutKind(kept[0])
+}
+
+// choose picks the layout for the column. Day-first and month-first layouts
+// that both fit every value are reported as ambiguous and settled by
+// monthFirst.
+func (c *temporalCandidates) choose(monthFirst bool) (layout string, ambiguous []string) {
+	if len(c.layouts) == 0 {
+		return "", nil
+	}
+	layout = c.layouts[0]
+	kind := layoutKind(layout)
+	first, ok := dayOrder(layout)
+	if !ok {
+		return layout, nil
+	}
+	for _, l := range c.layouts[1:] {
+		if layoutKind(l) != kind {
+			continue
+		}
+		if other, ok := dayOrder(l); ok && other != first {
+			ambiguous = []string{layout, l}
+			if first == monthFirst {
+				layout = l
+			}
+			break
+		}
+	}
+	return layout, ambiguous
+}
+
+// unixOnly reports whether only Unix pseudo-layouts still fit, which is the
+// one case where a numeric column is read as timestamps
+func (c *temporalCandidates) unixOnly() bool {
+	for _, l := range c.layouts {
+		if !isUnixLayout(l) {
+			return false
+		}
+	}
+	return len(c.layouts) > 0
+}
@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemporalCandidatesChoose(t *testing.T) {
+	cases := []struct {
+		name          string
+		column        string
+		values        []string
+		monthFirst    bool
+		wantLayout    string
+		wantAmbiguous []string
+	}{
+		{
+			name:          "ambiguous read day first",
+			column:        "day",
+			values:        []string{"03/04/2024", "05/06/2024"},
+			wantLayout:    "2/1/2006",
+			wantAmbiguous: []string{"2/1/2006", "1/2/2006"},
+		},
+		{
+			name:          "ambiguous read month first",
+			column:        "day",
+			values:        []string{"03/04/2024", "05/06/2024"},
+			monthFirst:    true,
+			wantLayout:    "1/2/2006",
+			wantAmbiguous: []string{"2/1/2006", "1/2/2006"},
+		},
+		{
+			name:       "day above twelve settles day first",
+			column:     "day",
+			values:     []string{"03/04/2024", "25/12/2024"},
+			monthFirst: true,
+			wantLayout: "2/1/2006",
+		},
+		{
+			name:       "day above twelve settles month first",
+			column:     "day",
+			values:     []string{"03/04/2024", "12/25/2024"},
+			wantLayout: "1/2/2006",
+		},
+		{
+			name:       "dotted dates are only day first",
+			column:     "day",
+			values:     []string{"03.04.2024"},
+			monthFirst: true,
+			wantLayout: "2.1.2006",
+		},
+		{
+			name:       "ISO dates have no day order",
+			column:     "day",
+			values:     []string{"2024-03-04"},
+			wantLayout: "2006-01-02",
+		},
+		{
+			name:          "ambiguous timestamps",
+			column:        "at",
+			values:        []string{"03/04/2024 10:00"},
+			monthFirst:    true,
+			wantLayout:    "1/2/2006 15:04",
+			wantAmbiguous: []string{"2/1/2006 15:04", "1/2/2006 15:04"},
+		},
+		{
+			name:       "Unix seconds on a time-like name",
+			column:     "created_at",
+			values:     []string{"1700000000"},
+			wantLayout: LayoutUnix,
+		},
+		{
+			name:   "Unix seconds on another name",
+			column: "amount",
+			values: []string{"1700000000"},
+		},
+		{
+			name:   "no layout fits",
+			column: "day",
+			values: []string{"03/04/2024", "yesterday"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			candidates := newTemporalCandidates(c.column, DefaultTemporalLayouts)
+			for _, v := range c.values {
+				candidates.observe(v)
+			}
+			layout, ambiguous := candidates.choose(c.monthFirst)
+			if layout != c.wantLayout || !reflect.DeepEqual(ambiguous, c.wantAmbiguous) {
+				t.Errorf("choose(%v) = %q, %q; want %q, %q", c.monthFirst, layout, ambiguous, c.wantLayout, c.wantAmbiguous)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/devakdogan/go_csv_adapter/internal/importer"
+)
+
+// Types offered in the preview, as importer.ParseColumnType reads them
+var columnTypeNames = []string{
+	"bool", "int", "bigint", "decimal", "float",
+	"date", "time", "timestamp", "timestamptz",
+	"uuid", "json", "string",
+}
+
+// planEditor holds the preview widgets of one CSV file
+type planEditor struct {
+	plan     *importer.FilePlan
+	include  *widget.Check
+	table    *widget.Entry
+	ifExists *widget.Select
+	columns  []columnEditor
+}
+
+type columnEditor struct {
+	// field is the position of the column in the CSV header
+	field  int
+	header string
+	// typeName is the type shown when the preview opened
+	typeName string
+	name     *widget.Entry
+	typ      *widget.Select
+	skip     *widget.Check
+	// key makes the column one of the merge keys
+	key *widget.Check
+}
+
+func newPlanEditor(t map[string]string, plan *importer.FilePlan) (*planEditor, fyne.CanvasObject) {
+	e := &planEditor{plan: plan}
+	e.include = widget.NewCheck(t["ImportFile"], nil)
+	e.include.SetChecked(true)
+	title := widget.NewLabelWithStyle(plan.FileName, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	if plan.Err != nil {
+		// Left ticked so the failure shows up in the import log
+		return e, container.NewVBox(container.NewHBox(title, layout.NewSpacer(), e.include), widget.NewLabel(plan.Err.Error()))
+	}
+
+	e.table = widget.NewEntry()
+	e.table.SetText(plan.Schema.Table)
+	e.ifExists = widget.NewSelect(conflictStrategyNames(), nil)
+	e.ifExists.Selected = string(plan.IfExists)
+
+	grid := container.NewGridWithColumns(6,
+		widget.NewLabelWithStyle(t["CSVColumn"], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(t["ColumnName"], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(t["ColumnType"], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(t["Skip"], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(t["Key"], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(t["Samples"], fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+	)
+	for i, col := range plan.Schema.Columns {
+		c := columnEditor{
+			field:    plan.Schema.Fields[i],
+			header:   plan.Schema.Headers[plan.Schema.Fields[i]],
+			typeName: col.TypeName(),
+			name:     widget.NewEntry(),
+			skip:     widget.NewCheck("", nil),
+			key:      widget.NewCheck("", nil),
+		}
+		c.name.SetText(col.Name)
+		c.key.SetChecked(containsString(plan.Schema.MergeKeys, col.Name))
+
+		options := columnTypeNames
+		if !containsString(options, c.typeName) {
+			options = append([]string{c.typeName}, options...)
+		}
+		c.typ = widget.NewSelect(options, nil)
+		c.typ.Selected = c.typeName
+
+		samples := widget.NewLabel(strings.Join(col.Examples, ", "))
+		samples.Truncation = fyne.TextTruncateEllipsis
+
+		grid.Add(widget.NewLabel(c.header))
+		grid.Add(c.name)
+		grid.Add(c.typ)
+		grid.Add(c.skip)
+		grid.Add(c.key)
+		grid.Add(samples)
+		e.columns = append(e.columns, c)
+	}
+
+	header := container.NewHBox(title, layout.NewSpacer(), e.include)
+	tableRow := container.NewBorder(nil, nil, widget.NewLabel(t["Table"]),
+		container.NewHBox(widget.NewLabel(t["IfExists"]), e.ifExists), e.table)
+	return e, container.NewVBox(header, tableRow, grid, widget.NewSeparator())
+}
+
+// apply writes the widgets back into the plan
+func (e *planEditor) apply() error {
+	if e.plan.Err != nil {
+		return nil
+	}
+	e.plan.IfExists = importer.ConflictStrategy(e.ifExists.Selected)
+	edits := make(map[int]importer.ColumnEdit, len(e.columns))
+	for _, c := range e.columns {
+		edit := importer.ColumnEdit{Name: strings.TrimSpace(c.name.Text), Skip: c.skip.Checked, Key: c.key.Checked}
+		if c.typ.Selected != c.typeName {
+			edit.Type = c.typ.Selected
+		}
+		edits[c.field] = edit
+	}
+	return e.plan.Edit(strings.TrimSpace(e.table.Text), edits)
+}
+
+// showImportPreview lists what every CSV file will be imported as and lets
+// the user rename tables and columns, change types, pick merge keys and skip
+// columns or files before anything touches the database. onImport gets the
+// files to import.
+func showImportPreview(mainWindow fyne.Window, lang *string, plans []*importer.FilePlan, onImport func([]*importer.FilePlan), onClose func()) {
+	t := translations[*lang]
+
+	var editors []*planEditor
+	files := container.NewVBox()
+	for _, plan := range plans {
+		editor, content := newPlanEditor(t, plan)
+		editors = append(editors, editor)
+		files.Add(content)
+	}
+	scroll := container.NewVScroll(files)
+	scroll.SetMinSize(fyne.NewSize(850, 450))
+
+	var previewDialog dialog.Dialog
+
+	closeBtn := widget.NewButton(t["Close"], func() {
+		previewDialog.Hide()
+	})
+
+	importBtn := widget.NewButton(t["StartImport"], func() {
+		var selected []*importer.FilePlan
+		for _, e := range editors {
+			if !e.include.Checked {
+				continue
+			}
+			if err := e.apply(); err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			selected = append(selected, e.plan)
+		}
+		if len(selected) == 0 {
+			dialog.ShowError(fmt.Errorf("%s", t["NothingToImport"]), mainWindow)
+			return
+		}
+		previewDialog.Hide()
+		onImport(selected)
+	})
+	importBtn.Importance = widget.HighImportance
+
+	content := container.NewBorder(nil,
+		container.NewHBox(layout.NewSpacer(), importBtn, closeBtn, layout.NewSpacer()),
+		nil, nil, scroll)
+
+	previewDialog = dialog.NewCustomWithoutButtons(t["Preview"], content, mainWindow)
+	previewDialog.Resize(fyne.NewSize(900, 600))
+	previewDialog.SetOnClosed(onClose)
+	previewDialog.Show()
+}
+
+func conflictStrategyNames() []string {
+	names := make([]string, len(importer.ConflictStrategies))
+	for i, c := range importer.ConflictStrategies {
+		names[i] = string(c)
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
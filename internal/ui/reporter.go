@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// Minimum time a loading animation stays visible, so quick steps don't flicker
+const minLoadingTime = 1 * time.Second
+
+// fyneReporter shows importer output in the log TextGrid and progress bar
+type fyneReporter struct {
+	mu          sync.Mutex
+	grid        *widget.TextGrid
+	progressBar *widget.ProgressBar
+	phases      map[string]*phaseHandle
+}
+
+type phaseHandle struct {
+	started time.Time
+	loading *LoadingHandle
+}
+
+func newFyneReporter(grid *widget.TextGrid, progressBar *widget.ProgressBar) *fyneReporter {
+	return &fyneReporter{
+		grid:        grid,
+		progressBar: progressBar,
+		phases:      make(map[string]*phaseHandle),
+	}
+}
+
+func (r *fyneReporter) Log(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	appendLog(r.grid, message)
+}
+
+func (r *fyneReporter) PhaseStart(phase string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.phases[phase] = &phaseHandle{
+		started: time.Now(),
+		loading: StartLoadingAnimation(r.grid, phase),
+	}
+}
+
+func (r *fyneReporter) PhaseEnd(phase string, err error) {
+	r.mu.Lock()
+	handle := r.phases[phase]
+	delete(r.phases, phase)
+	r.mu.Unlock()
+
+	if handle != nil {
+		if elapsed := time.Since(handle.started); elapsed < minLoadingTime {
+			time.Sleep(minLoadingTime - elapsed)
+		}
+		handle.loading.Stop(err)
+		return
+	}
+	if err != nil {
+		r.Log(fmt.Sprintf("%s - Failed: %v", phase, err))
+	}
+}
+
+func (r *fyneReporter) FileStart(name string, index int, total int) {
+	r.progressBar.SetValue(0)
+	r.Log(fmt.Sprintf("Processing file: %s (%d/%d)", name, index+1, total))
+}
+
+func (r *fyneReporter) FileEnd(name string, err error) {
+	if err != nil {
+		r.Log(fmt.Sprintf("Error: %v", err))
+	}
+}
+
+func (r *fyneReporter) TableProgress(table string, percent int) {
+	r.progressBar.SetValue(float64(percent))
+}
+
+func (r *fyneReporter) Error(err error) {
+	r.Log(fmt.Sprintf("Error: %v", err))
+}
+
+type LoadingHandle struct {
+	stopChan      chan error
+	animationDone chan bool
+}
+
+func StartLoadingAnimation(logOutput *widget.TextGrid, baseMessage string) *LoadingHandle {
+	stopChan := make(chan error)
+	animationDone := make(chan bool)
+
+	go func() {
+		loadingStates := []string{".", "..", "..."}
+		currentText := logOutput.Text()
+		lastLineIndex := strings.LastIndex(currentText, "\n")
+		if lastLineIndex == -1 {
+			lastLineIndex = 0
+		} else {
+			lastLineIndex += 1
+		}
+		baseText := currentText[:lastLineIndex]
+		i := 0
+
+		for {
+			select {
+			case err := <-stopChan:
+				timestamp := time.Now().Format("15:04:05")
+				outcome := "Complete"
+				if err != nil {
+					outcome = fmt.Sprintf("Failed: %v", err)
+				}
+				finalText := fmt.Sprintf("%s[%s] %s - %s\n",
+					baseText, timestamp, baseMessage, outcome)
+				logOutput.SetText(finalText)
+				logOutput.Refresh()
+				animationDone <- true
+				return
+			default:
+				timestamp := time.Now().Format("15:04:05")
+				animationText := fmt.Sprintf("%s[%s] %s%s",
+					baseText, timestamp, baseMessage, loadingStates[i])
+				logOutput.SetText(animationText)
+				logOutput.Refresh()
+				i = (i + 1) % len(loadingStates)
+				time.Sleep(500 * time.Millisecond)
+			}
+		}
+	}()
+
+	return &LoadingHandle{
+		stopChan:      stopChan,
+		animationDone: animationDone,
+	}
+}
+
+// Stop ends the animation, marking the line failed when err is set
+func (h *LoadingHandle) Stop(err error) {
+	h.stopChan <- err
+	<-h.animationDone
+}
@@ -6,12 +6,15 @@ import (
 	"github.com/devakdogan/go_csv_adapter/internal/importer"
 	"image/color"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
@@ -35,6 +38,39 @@ var translations = map[string]map[string]string{
 		"Edit":             "Edit",
 		"Close":            "Close",
 		"ConfigureDB":      "Configure Database",
+		"Transactional":    "All-or-nothing per file",
+		"LoadMethod":       "Load method:",
+		"BatchSize":        "Batch size (0 = auto):",
+		"Workers":          "Workers:",
+		"SampleSize":       "Sample rows (0 = all):",
+		"MonthFirst":       "Month-first dates (MM/DD)",
+		"DryRun":           "Dry run (no writes)",
+		"Delimiter":        "Delimiter:",
+		"Encoding":         "Encoding:",
+		"SkipLines":        "Lines before header:",
+		"NoHeader":         "No header row",
+		"MaxErrors":        "Max rejected rows:",
+		"IfExists":         "If table exists:",
+		"ReadingFiles":     "Reading the CSV files...",
+		"MergeKeys":        "Merge on columns:",
+		"EvolveSchema":     "Add new columns to tables",
+		"Key":              "Key",
+		"EmptyIsNull":      "Empty cells are NULL",
+		"NullTokens":       "Other NULL values:",
+		"ShortRows":        "Short rows:",
+		"LongRows":         "Long rows:",
+		"SnakeCase":        "snake_case column names",
+		"Transliterate":    "Turkish letters to ASCII",
+		"AvoidReserved":    "Rename reserved words",
+		"Preview":          "Review Import",
+		"ImportFile":       "Import",
+		"Table":            "Table:",
+		"CSVColumn":        "CSV column",
+		"ColumnName":       "Name",
+		"ColumnType":       "Type",
+		"Skip":             "Skip",
+		"Samples":          "Samples",
+		"NothingToImport":  "No files are selected for import",
 	},
 	"Türkçe": {
 		"DatabaseType":     "Veritabanı Türü:",
@@ -52,15 +88,46 @@ var translations = map[string]map[string]string{
 		"Edit":             "Düzenle",
 		"Close":            "Kapat",
 		"ConfigureDB":      "Veritabanı Yapılandırması",
+		"Transactional":    "Dosya başına ya hep ya hiç",
+		"LoadMethod":       "Yükleme yöntemi:",
+		"BatchSize":        "Toplu boyut (0 = otomatik):",
+		"Workers":          "İşçi sayısı:",
+		"SampleSize":       "Örnek satır (0 = tümü):",
+		"MonthFirst":       "Tarihlerde önce ay (AA/GG)",
+		"DryRun":           "Deneme (yazmadan)",
+		"Delimiter":        "Ayraç:",
+		"Encoding":         "Kodlama:",
+		"SkipLines":        "Başlıktan önceki satırlar:",
+		"NoHeader":         "Başlık satırı yok",
+		"MaxErrors":        "En fazla reddedilen satır:",
+		"IfExists":         "Tablo varsa:",
+		"ReadingFiles":     "CSV dosyaları okunuyor...",
+		"MergeKeys":        "Birleştirme sütunları:",
+		"EvolveSchema":     "Tablolara yeni sütun ekle",
+		"Key":              "Anahtar",
+		"EmptyIsNull":      "Boş hücreler NULL",
+		"NullTokens":       "Diğer NULL değerleri:",
+		"ShortRows":        "Eksik alanlı satırlar:",
+		"LongRows":         "Fazla alanlı satırlar:",
+		"SnakeCase":        "snake_case sütun adları",
+		"Transliterate":    "Türkçe harfleri ASCII yap",
+		"AvoidReserved":    "Ayrılmış sözcükleri yeniden adlandır",
+		"Preview":          "İçe Aktarımı Gözden Geçir",
+		"ImportFile":       "İçe aktar",
+		"Table":            "Tablo:",
+		"CSVColumn":        "CSV sütunu",
+		"ColumnName":       "Ad",
+		"ColumnType":       "Tür",
+		"Skip":             "Atla",
+		"Samples":          "Örnekler",
+		"NothingToImport":  "İçe aktarılacak dosya seçilmedi",
 	},
 }
 
+// dbConfig is the connection the form is bound to, plus whether the user
+// has confirmed it yet
 type dbConfig struct {
-	Host       *widget.Entry
-	Port       *widget.Entry
-	User       *widget.Entry
-	Password   *widget.Entry
-	Database   *widget.Entry
+	db.DbConfig
 	Configured bool
 }
 
@@ -90,7 +157,8 @@ func StartApp() {
 	currentLang := "English"
 	selectedDB := new(string)
 	config := &dbConfig{}
-	isPopupOpen := new(bool)
+	importOptions := importer.DefaultOptions()
+	isPopupOpen := new(atomic.Bool)
 	folderPath := widget.NewLabel(translations[currentLang]["NoFolderSelected"])
 	folderPath.Wrapping = fyne.TextTruncate
 
@@ -108,15 +176,10 @@ func StartApp() {
 	progressBar.TextFormatter = func() string {
 		return fmt.Sprintf("%d%%", int(progressBar.Value))
 	}
-	
-	updateProgress := func(workerID int, percent int) {
-		// Update the progress bar directly
-		progressBar.SetValue(float64(percent))
-	}
 
 	var updateUI func()
 	updateUI = func() {
-		w.SetContent(buildUI(w, &currentLang, config, selectedDB, folderPath, updateUI, isPopupOpen, logOutput, updateProgress))
+		w.SetContent(buildUI(w, &currentLang, config, selectedDB, folderPath, updateUI, isPopupOpen, logOutput, &importOptions))
 	}
 	updateUI()
 	w.ShowAndRun()
@@ -147,8 +210,7 @@ func (t *customTheme) Color(n fyne.ThemeColorName, v fyne.ThemeVariant) color.Co
 }
 
 func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string, folderPath *widget.Label,
-	refreshFunc func(), isPopupOpen *bool, logOutput *widget.TextGrid, 
-	updateProgress func(int, int)) fyne.CanvasObject {
+	refreshFunc func(), isPopupOpen *atomic.Bool, logOutput *widget.TextGrid, importOptions *importer.Options) fyne.CanvasObject {
 	t := translations[*lang]
 
 	langSelect := widget.NewSelect([]string{"English", "Türkçe"}, func(selected string) {
@@ -178,17 +240,17 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 		icon.Resize(fyne.NewSize(48, 48))
 
 		dbButton := widget.NewButton("", func() {
-			if *isPopupOpen {
+			if isPopupOpen.Load() {
 				return
 			}
 			if *selectedDB != dbNameCopy {
 				*config = dbConfig{}
 				*selectedDB = dbNameCopy
-				*isPopupOpen = true
+				isPopupOpen.Store(true)
 				showDBPopup(w, lang, config, dbNameCopy, func() {
 					refreshFunc()
 				}, func() {
-					*isPopupOpen = false
+					isPopupOpen.Store(false)
 				})
 			}
 		})
@@ -197,14 +259,14 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 		if isSelected {
 			dbButton.Importance = widget.HighImportance
 			editButton := widget.NewButton(t["Edit"], func() {
-				if *isPopupOpen {
+				if isPopupOpen.Load() {
 					return
 				}
-				*isPopupOpen = true
+				isPopupOpen.Store(true)
 				showDBPopup(w, lang, config, dbNameCopy, func() {
 					refreshFunc()
 				}, func() {
-					*isPopupOpen = false
+					isPopupOpen.Store(false)
 				})
 			})
 			editButton.Resize(fyne.NewSize(120, 30))
@@ -270,7 +332,7 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 	pathContainer := container.NewHBox(pathLabel, pathText)
 
 	folderButton := widget.NewButton(t["ChooseFolder"], func() {
-		if *isPopupOpen {
+		if isPopupOpen.Load() {
 			return
 		}
 		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
@@ -283,14 +345,14 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 	folderButton.Resize(fyne.NewSize(150, 40))
 
 	importButton := widget.NewButton(t["StartImport"], func() {
-		if *isPopupOpen {
+		if isPopupOpen.Load() {
 			return
 		}
 		if *selectedDB == "" {
 			appendLog(logOutput, "Error: Please select a database type first")
 			return
 		}
-		if !config.Configured {
+		if !config.Configured && !importOptions.DryRun {
 			appendLog(logOutput, "Error: Please configure the database connection first")
 			return
 		}
@@ -298,18 +360,237 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 			appendLog(logOutput, "Error: Please select a CSV folder first")
 			return
 		}
+		if importOptions.Workers < 1 {
+			appendLog(logOutput, "Error: Workers must be at least 1")
+			return
+		}
 
-		appendLog(logOutput, fmt.Sprintf("Starting import process for %s database...", *selectedDB))
-		appendLog(logOutput, fmt.Sprintf("Using folder: %s", folderPath.Text))
-		appendLog(logOutput, fmt.Sprintf("Database: %s@%s:%s/%s", config.User.Text, config.Host.Text, config.Port.Text, config.Database.Text))
+		// Nothing touches the database until the preview is confirmed.
+		// Planning reads every file, which can take a while with a full
+		// scan, and the load itself takes longer still, so both run in the
+		// background and the window stays responsive. The flag is atomic
+		// since those goroutines clear it; it keeps the buttons from
+		// starting anything else until the import has finished.
+		isPopupOpen.Store(true)
+		appendLog(logOutput, t["ReadingFiles"])
+		folder, dbType, planOptions := folderPath.Text, *selectedDB, *importOptions
+		go func() {
+			plans, err := importer.PlanImport(folder, dbType, planOptions)
+			if err != nil {
+				appendLog(logOutput, fmt.Sprintf("Error: %v", err))
+				isPopupOpen.Store(false)
+				return
+			}
+			if len(plans) == 0 {
+				appendLog(logOutput, "Error: No CSV files found in the selected folder")
+				isPopupOpen.Store(false)
+				return
+			}
+			// Fyne 2.4 widgets and dialogs may be used from any goroutine;
+			// the preview's buttons call back on the UI goroutine
+			showImportPreview(w, lang, plans, func(selected []*importer.FilePlan) {
+				isPopupOpen.Store(true)
+				options, connConfig := *importOptions, config.DbConfig
+				if options.DryRun {
+					appendLog(logOutput, fmt.Sprintf("Starting dry run for %s database...", dbType))
+					appendLog(logOutput, fmt.Sprintf("Using folder: %s", folder))
+				} else {
+					appendLog(logOutput, fmt.Sprintf("Starting import process for %s database...", dbType))
+					appendLog(logOutput, fmt.Sprintf("Using folder: %s", folder))
+					appendLog(logOutput, fmt.Sprintf("Database: %s@%s:%d/%s", config.User, config.Host, config.Port, config.Database))
+				}
 
-		importer.ImportCSVFiles(folderPath.Text, *selectedDB, (*db.DbConfig)(config), logOutput, func(workerID int, percent int) {
-			// Update the progress bar directly
-			progressBar.SetValue(float64(percent))
-		})
+				go func() {
+					defer isPopupOpen.Store(false)
+					_ = importer.ExecutePlans(selected, dbType, connConfig, options,
+						newFyneReporter(logOutput, progressBar))
+				}()
+			}, func() {
+				isPopupOpen.Store(false)
+			})
+		}()
 	})
 	importButton.Resize(fyne.NewSize(150, 40))
 
+	transactionalCheck := widget.NewCheck(t["Transactional"], func(checked bool) {
+		importOptions.Transactional = checked
+	})
+	transactionalCheck.SetChecked(importOptions.Transactional)
+
+	// Only decides dates like 03/04/2024 that fit both orders
+	monthFirstCheck := widget.NewCheck(t["MonthFirst"], func(checked bool) {
+		importOptions.MonthFirst = checked
+	})
+	monthFirstCheck.SetChecked(importOptions.MonthFirst)
+
+	// Also widens columns of existing tables where no value is lost
+	evolveSchemaCheck := widget.NewCheck(t["EvolveSchema"], func(checked bool) {
+		importOptions.EvolveSchema = checked
+	})
+	evolveSchemaCheck.SetChecked(importOptions.EvolveSchema)
+
+	// auto detects the delimiter and encoding of each file separately
+	delimiterSelect := widget.NewSelect([]string{"auto", ",", ";", "tab", "|"}, func(selected string) {
+		switch selected {
+		case "auto":
+			importOptions.CSV.Delimiter = 0
+		case "tab":
+			importOptions.CSV.Delimiter = '\t'
+		default:
+			importOptions.CSV.Delimiter = []rune(selected)[0]
+		}
+	})
+	switch importOptions.CSV.Delimiter {
+	case 0:
+		delimiterSelect.Selected = "auto"
+	case '\t':
+		delimiterSelect.Selected = "tab"
+	default:
+		delimiterSelect.Selected = string(importOptions.CSV.Delimiter)
+	}
+	encodingSelect := widget.NewSelect([]string{
+		importer.EncodingAuto, importer.EncodingUTF8, "windows-1254", "iso-8859-9", "windows-1252", "utf-16le",
+	}, func(selected string) {
+		importOptions.CSV.Encoding = selected
+	})
+	encodingSelect.Selected = importOptions.CSV.Encoding
+	if encodingSelect.Selected == "" {
+		encodingSelect.Selected = importer.EncodingAuto
+	}
+
+	// Headerless columns are named col_1..col_n unless a schema file names them
+	noHeaderCheck := widget.NewCheck(t["NoHeader"], func(checked bool) {
+		importOptions.CSV.NoHeader = checked
+	})
+	noHeaderCheck.SetChecked(importOptions.CSV.NoHeader)
+	skipLinesEntry := newNumberEntry(&importOptions.CSV.SkipLines, 1000)
+
+	// Null tokens: empty cells get a check of their own, the others are
+	// typed comma-separated. Schema files can set them per column.
+	emptyIsNull := containsString(importOptions.NullTokens, "")
+	var otherNulls []string
+	for _, token := range importOptions.NullTokens {
+		if token != "" {
+			otherNulls = append(otherNulls, token)
+		}
+	}
+	nullEntry := widget.NewEntry()
+	nullEntry.SetText(strings.Join(otherNulls, ", "))
+	nullEntry.SetPlaceHolder(`NULL, \N, N/A`)
+	updateNullTokens := func() {
+		var tokens []string
+		if emptyIsNull {
+			tokens = append(tokens, "")
+		}
+		for _, token := range strings.Split(nullEntry.Text, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+		importOptions.NullTokens = tokens
+	}
+	emptyIsNullCheck := widget.NewCheck(t["EmptyIsNull"], func(checked bool) {
+		emptyIsNull = checked
+		updateNullTokens()
+	})
+	emptyIsNullCheck.SetChecked(emptyIsNull)
+	nullEntry.OnChanged = func(string) { updateNullTokens() }
+
+	// Rejected rows go to <file>.csv.rejected.csv next to the CSV file
+	shortRowsSelect := widget.NewSelect([]string{
+		string(importer.RaggedPad), string(importer.RaggedReject), string(importer.RaggedAbort),
+	}, func(selected string) {
+		importOptions.ShortRows = importer.RaggedPolicy(selected)
+	})
+	shortRowsSelect.Selected = string(importOptions.ShortRows)
+	longRowsSelect := widget.NewSelect([]string{
+		string(importer.RaggedTruncate), string(importer.RaggedReject), string(importer.RaggedAbort),
+	}, func(selected string) {
+		importOptions.LongRows = importer.RaggedPolicy(selected)
+	})
+	longRowsSelect.Selected = string(importOptions.LongRows)
+
+	// Every header rename is listed in the import log
+	snakeCaseCheck := widget.NewCheck(t["SnakeCase"], func(checked bool) {
+		importOptions.Headers.SnakeCase = checked
+	})
+	snakeCaseCheck.SetChecked(importOptions.Headers.SnakeCase)
+	transliterateCheck := widget.NewCheck(t["Transliterate"], func(checked bool) {
+		importOptions.Headers.Transliterate = checked
+	})
+	transliterateCheck.SetChecked(importOptions.Headers.Transliterate)
+	avoidReservedCheck := widget.NewCheck(t["AvoidReserved"], func(checked bool) {
+		importOptions.Headers.AvoidReserved = checked
+	})
+	avoidReservedCheck.SetChecked(importOptions.Headers.AvoidReserved)
+
+	// Validates every row and logs the DDL without connecting
+	dryRunCheck := widget.NewCheck(t["DryRun"], func(checked bool) {
+		importOptions.DryRun = checked
+	})
+	dryRunCheck.SetChecked(importOptions.DryRun)
+
+	// native = COPY on PostgreSQL, LOAD DATA LOCAL INFILE on MySQL (needs local_infile=ON)
+	loadMethodSelect := widget.NewSelect([]string{
+		string(importer.LoadAuto), string(importer.LoadInsert), string(importer.LoadNative),
+	}, func(selected string) {
+		if method, err := importer.ParseLoadMethod(selected); err == nil {
+			importOptions.LoadMethod = method
+		}
+	})
+	loadMethodSelect.Selected = string(importOptions.LoadMethod)
+
+	// The preview can still pick another strategy per file
+	ifExistsSelect := widget.NewSelect(conflictStrategyNames(), func(selected string) {
+		importOptions.IfExists = importer.ConflictStrategy(selected)
+	})
+	ifExistsSelect.Selected = string(importOptions.IfExists)
+
+	// Rows whose key columns are already in the table update that row
+	mergeKeysEntry := widget.NewEntry()
+	mergeKeysEntry.SetText(strings.Join(importOptions.MergeKeys, ", "))
+	mergeKeysEntry.SetPlaceHolder("id, email")
+	mergeKeysEntry.OnChanged = func(text string) {
+		importOptions.MergeKeys = importer.ParseMergeKeys(text)
+	}
+
+	// Batch size is capped per table by the engine's parameter limit for INSERTs
+	batchSizeEntry := newNumberEntry(&importOptions.BatchSize, 100000)
+	workersEntry := newNumberEntry(&importOptions.Workers, 64)
+	// Rows type inference looks at before choosing column types
+	sampleSizeEntry := newNumberEntry(&importOptions.SampleSize, 10000000)
+	// Rows the database refuses go to <file>.csv.rejected.csv; past this
+	// many the file is stopped
+	maxErrorsEntry := newNumberEntry(&importOptions.MaxErrors, 10000000)
+	settingsSection := container.NewVBox(
+		container.NewHBox(
+			widget.NewLabel(t["BatchSize"]), batchSizeEntry,
+			widget.NewLabel(t["Workers"]), workersEntry,
+			widget.NewLabel(t["SampleSize"]), sampleSizeEntry,
+			layout.NewSpacer(),
+			widget.NewLabel(t["IfExists"]), ifExistsSelect,
+			widget.NewLabel(t["LoadMethod"]), loadMethodSelect,
+		),
+		container.NewHBox(
+			widget.NewLabel(t["Delimiter"]), delimiterSelect,
+			widget.NewLabel(t["Encoding"]), encodingSelect,
+			widget.NewLabel(t["SkipLines"]), skipLinesEntry, noHeaderCheck,
+			layout.NewSpacer(), evolveSchemaCheck,
+			widget.NewLabel(t["MergeKeys"]), container.NewGridWrap(fyne.NewSize(160, 36), mergeKeysEntry),
+		),
+		container.NewHBox(
+			snakeCaseCheck, transliterateCheck, avoidReservedCheck, layout.NewSpacer(),
+			widget.NewLabel(t["ShortRows"]), shortRowsSelect,
+			widget.NewLabel(t["LongRows"]), longRowsSelect,
+			widget.NewLabel(t["MaxErrors"]), maxErrorsEntry,
+		),
+		container.NewHBox(
+			transactionalCheck, monthFirstCheck,
+			emptyIsNullCheck, widget.NewLabel(t["NullTokens"]), container.NewGridWrap(fyne.NewSize(160, 36), nullEntry),
+			layout.NewSpacer(), dryRunCheck,
+		),
+	)
+
 	bottomSection := container.NewHBox(folderButton, layout.NewSpacer(), importButton)
 	mainContent := container.NewVBox(
 		topRight,
@@ -317,6 +598,7 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 		container.NewPadded(logsBox),
 		progressBox,
 		pathContainer,
+		settingsSection,
 		bottomSection,
 	)
 	return container.NewPadded(mainContent)
@@ -325,76 +607,24 @@ func buildUI(w fyne.Window, lang *string, config *dbConfig, selectedDB *string,
 func showDBPopup(mainWindow fyne.Window, lang *string, config *dbConfig, dbType string, onConfirm func(), onClose func()) {
 	t := translations[*lang]
 
-	if config.Host == nil {
-		config.Host = widget.NewEntry()
-	}
-	if config.Port == nil {
-		config.Port = widget.NewEntry()
-	}
-	config.Port.OnChanged = func(s string) {
-		filtered := ""
-		for _, r := range s {
-			if r >= '0' && r <= '9' {
-				filtered += string(r)
-			}
-		}
-		if len(filtered) > 5 {
-			filtered = filtered[:5]
-		}
-		if filtered != s {
-			config.Port.SetText(filtered)
-			return
-		}
-		if filtered != "" {
-			if val, err := strconv.Atoi(filtered); err == nil && val > 65536 {
-				config.Port.SetText("65536")
-			}
-		}
-	}
-	if config.User == nil {
-		config.User = widget.NewEntry()
-	}
-	if config.Password == nil {
-		config.Password = widget.NewPasswordEntry()
-	}
-	if config.Database == nil {
-		config.Database = widget.NewEntry()
-	}
-
-	if config.Configured {
-		config.Host.SetText(config.Host.Text)
-		config.Port.SetText(config.Port.Text)
-		config.User.SetText(config.User.Text)
-		config.Password.SetText(config.Password.Text)
-		config.Database.SetText(config.Database.Text)
-	}
-
 	if !config.Configured {
-		switch dbType {
-		case "PostgreSQL":
-			config.Host.SetText("localhost")
-			config.Port.SetText("5432")
-			config.User.SetText("postgres")
-			config.Database.SetText("postgres")
-		case "MySQL":
-			config.Host.SetText("localhost")
-			config.Port.SetText("3306")
-			config.User.SetText("root")
-			config.Database.SetText("mysql")
-		case "SQLite":
-			config.Host.SetText("localhost")
-			config.Port.SetText("0")
-			config.User.SetText("root")
-			config.Database.SetText("local.db")
-		}
+		config.DbConfig = db.DefaultDbConfig(dbType)
 	}
 
+	// The entries write straight through to config.DbConfig
+	hostEntry := widget.NewEntryWithData(binding.BindString(&config.Host))
+	portEntry := newNumberEntry(&config.Port, 65535)
+	userEntry := widget.NewEntryWithData(binding.BindString(&config.User))
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.Bind(binding.BindString(&config.Password))
+	databaseEntry := widget.NewEntryWithData(binding.BindString(&config.Database))
+
 	form := widget.NewForm(
-		&widget.FormItem{Text: t["Host"], Widget: config.Host},
-		&widget.FormItem{Text: t["Port"], Widget: config.Port},
-		&widget.FormItem{Text: t["User"], Widget: config.User},
-		&widget.FormItem{Text: t["Password"], Widget: config.Password},
-		&widget.FormItem{Text: t["Database"], Widget: config.Database},
+		&widget.FormItem{Text: t["Host"], Widget: hostEntry},
+		&widget.FormItem{Text: t["Port"], Widget: portEntry},
+		&widget.FormItem{Text: t["User"], Widget: userEntry},
+		&widget.FormItem{Text: t["Password"], Widget: passwordEntry},
+		&widget.FormItem{Text: t["Database"], Widget: databaseEntry},
 	)
 
 	// Butonlar için container
@@ -408,8 +638,8 @@ func showDBPopup(mainWindow fyne.Window, lang *string, config *dbConfig, dbType
 	})
 
 	confirmBtn := widget.NewButton(t["Confirm"], func() {
-		if config.Host.Text == "" || config.Port.Text == "" || config.User.Text == "" || config.Password.Text == "" || config.Database.Text == "" {
-			dialog.ShowError(fmt.Errorf("All fields must be filled"), mainWindow)
+		if err := config.Validate(dbType); err != nil {
+			dialog.ShowError(err, mainWindow)
 			return
 		}
 
@@ -448,6 +678,33 @@ func showDBPopup(mainWindow fyne.Window, lang *string, config *dbConfig, dbType
 	customDialog.Show()
 }
 
+// newNumberEntry binds an entry to value, accepting only digits up to max
+func newNumberEntry(value *int, max int) *widget.Entry {
+	entry := widget.NewEntryWithData(binding.IntToString(binding.BindInt(value)))
+	maxText := strconv.Itoa(max)
+	entry.OnChanged = func(s string) {
+		filtered := ""
+		for _, r := range s {
+			if r >= '0' && r <= '9' {
+				filtered += string(r)
+			}
+		}
+		if len(filtered) > len(maxText) {
+			filtered = filtered[:len(maxText)]
+		}
+		if filtered != s {
+			entry.SetText(filtered)
+			return
+		}
+		if filtered != "" {
+			if val, err := strconv.Atoi(filtered); err == nil && val > max {
+				entry.SetText(maxText)
+			}
+		}
+	}
+	return entry
+}
+
 // Log mesajlarını eklemek için yardımcı fonksiyon
 // Using the package-level globalLogScroll variable
 